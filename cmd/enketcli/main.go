@@ -1,42 +1,147 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/aemo"
 	"github.com/georgesolomos/enket/internal/calculator"
 	"github.com/georgesolomos/enket/internal/energyplan"
+	"github.com/georgesolomos/enket/internal/gas"
 	"github.com/georgesolomos/enket/internal/nem12"
 )
 
+// Below this share of actual reads, we warn that a cost is mostly based on estimates
+const minActualShare = 0.9
+
 func main() {
 	slogOpts := slog.HandlerOptions{Level: slog.LevelInfo}
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slogOpts))
 	slog.SetDefault(logger)
 
-	nem12Path := flag.String("nem12path", "", "The path to your NEM12 file")
-	flag.Parse()
-	if *nem12Path == "" {
-		logger.Error("A NEM12 path must be provided")
-		os.Exit(1)
+	// Stop parsing cleanly if the user interrupts us
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "redact" {
+		err := redact(ctx, logger, os.Args[2:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
 	}
 
-	nem12File, err := os.Open(*nem12Path)
-	if err != nil {
-		logger.Error("Could not open NEM12 file")
+	var nem12Paths pathList
+	flag.Var(&nem12Paths, "nem12path",
+		"The path to your NEM12 file. Can be a directory or zip archive of NEM12 files, and can be given more than once.")
+	strict := flag.Bool("strict", false, "Fail on any violation of the NEM12 specification instead of skipping bad data")
+	impute := flag.String("impute", "none",
+		"How to fill gaps in the usage data: none, weekday (same weekday average), seasonal (seasonal profile) or interpolate")
+	actualOnly := flag.Bool("actualonly", false, "Leave out days with estimated or substituted readings when calculating costs. Gaps filled by -impute are kept.")
+	suffixes := suffixFlag{mapping: nem12.DefaultSuffixMapping()}
+	flag.Var(&suffixes, "suffix",
+		"Maps an NMI suffix (e.g. E3) or suffix prefix (e.g. E) to a reading type, e.g. E2=import. Can be given more than once.")
+	from := flag.String("from", "", "Also cost usage from this date (YYYY-MM-DD). Must be given with -to.")
+	to := flag.String("to", "", "Also cost usage up to but not including this date (YYYY-MM-DD). Must be given with -from.")
+	greenPower := flag.Float64("greenpower", 0, "The percentage of GreenPower to buy, e.g. 25, 50 or 100")
+	explain := flag.String("explain", "", "Print how the costs were worked out, as text or json")
+	bills := flag.Bool("bills", false, "Simulate the bills the plan would issue for the usage")
+	billFrequency := flag.String("billfrequency", "",
+		"How often bills are issued as an ISO 8601 duration, e.g. P3M. Defaults to the plan's bill frequency.")
+	horizon := flag.Int("horizon", 0,
+		"Also cost staying on the plan for this many months from today, with benefits expiring and exit fees applied")
+	intervalCSV := flag.String("intervalcsv", "", "Write the cost of every reading to this CSV file")
+	netCSV := flag.String("netcsv", "",
+		"Write the import and export of every interval to this CSV file, netted as they would be under net metering")
+	householdPath := flag.String("household", "", "The path to a JSON household profile listing concessions and rebates")
+	accountID := flag.String("accountid", "",
+		"Also fetch concessions for this energy account. Needs -retailerurl and an access token in ENKET_ACCESS_TOKEN.")
+	retailerURL := flag.String("retailerurl", "", "The retailer's CDR base URL, ending in /cds-au/v1")
+	gasReadsPath := flag.String("gasreads", "",
+		"The path to a CSV of gas reads from your bills, with a start date, end date and MJ used on each row")
+	gasPlanID := flag.String("gasplan", "", "The ID of the gas plan to cost the gas reads against. Must be given with -gasreads.")
+	dualFuel := flag.Bool("dualfuel", false,
+		"Compare dual fuel plans with the best separate electricity and gas plans. Must be given with -gasreads.")
+	retailers := flag.String("retailers", "origin", "Comma separated retailers to fetch plans from for -dualfuel")
+	postcode := flag.String("postcode", "", "Your postcode, so -dualfuel only compares plans sold where you live")
+	var pricePaths pathList
+	flag.Var(&pricePaths, "aemoprices",
+		"The path to an AEMO dispatch or trading price CSV, or a directory of them. Can be given more than once.")
+	region := flag.String("region", "", "Your NEM region for -aemoprices, e.g. NSW1")
+	passThroughPlanID := flag.String("passthroughplan", "",
+		"The ID of a plan that passes through wholesale spot prices. Its rates should be the network and retail fees.")
+	lossFactor := flag.Float64("lossfactor", 1, "The network loss factor applied to wholesale prices for -passthroughplan")
+	flag.Parse()
+	if len(nem12Paths) == 0 {
+		logger.Error("A NEM12 path must be provided")
 		os.Exit(1)
 	}
-	defer nem12File.Close()
 
-	parser := nem12.NewParser(logger, nem12File)
-	nem12Data, err := parser.Parse()
+	merger := nem12.NewMerger(logger, parseMode(*strict), suffixes.mapping)
+	nem12Data, err := merger.Merge(ctx, nem12Paths...)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
+	mergeReport := merger.Report()
+	for source, report := range mergeReport.Sources {
+		logger.Info("Parsed NEM12 file",
+			slog.String("path", source),
+			slog.Int("droppedIntervals", len(report.DroppedIntervals)),
+			slog.Bool("missing900", report.Missing900),
+			slog.Int("warnings", len(report.Warnings)))
+	}
+	logger.Info("Merged NEM12 files",
+		slog.Int("skippedBlocks", len(mergeReport.Merged.SkippedBlocks)),
+		slog.Int("replacedIntervals", mergeReport.Merged.ReplacedIntervals),
+		slog.Any("unsupportedSuffixes", mergeReport.Merged.UnsupportedSuffixes),
+		slog.Int("gaps", len(mergeReport.Gaps)))
+	for _, explanation := range mergeReport.Merged.Quality.Explain() {
+		logger.Info(explanation)
+	}
+	if *impute != "none" {
+		strategy, err := imputationStrategy(*impute)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		imputed, unfilled := nem12Data.Impute(strategy)
+		logger.Info("Filled gaps in usage data", slog.Int("hoursImputed", imputed), slog.Int("unfilledGaps", len(unfilled)))
+	}
+	if header := merger.File().Header; header != nil {
+		logger.Info("NEM12 provenance",
+			slog.Time("created", header.DateTime),
+			slog.String("from", header.FromParticipant),
+			slog.String("to", header.ToParticipant))
+	}
+	for _, check := range merger.File().CheckIndexReads() {
+		logger.Info("Index read check",
+			slog.String("nmi", check.NMI),
+			slog.String("suffix", check.Suffix),
+			slog.Time("from", check.From),
+			slog.Time("to", check.To),
+			slog.Float64("indexDelta", check.IndexDelta),
+			slog.Float64("intervalTotal", check.IntervalTotal),
+			slog.Float64("difference", check.Difference()))
+	}
+	if *netCSV != "" {
+		err = writeNetSeries(merger.File(), suffixes.mapping, *netCSV)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("Wrote net series", slog.String("path", *netCSV))
+	}
 
 	fetcher, err := energyplan.NewPlanFetcher(logger, "origin")
 	if err != nil {
@@ -44,7 +149,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	// plans, err := fetcher.FetchAllPlans()
+	// plans, err := fetcher.FetchAllPlans(cdsenergy.ListPlansParamsFuelTypeELECTRICITY)
 	// if err != nil {
 	// 	logger.Error(err.Error())
 	// }
@@ -56,15 +161,464 @@ func main() {
 		os.Exit(1)
 	}
 
-	calculator := calculator.NewCalculator(logger)
-	cost, err := calculator.CalculateMonthly(nem12Data, plan)
+	quality := calculator.IncludeEstimates
+	if *actualOnly {
+		quality = calculator.ActualOnly
+	}
+	if *greenPower < 0 || *greenPower > 100 {
+		logger.Error("The GreenPower percentage must be between 0 and 100")
+		os.Exit(1)
+	}
+	if !calculator.SupportsGreenPower(plan, *greenPower/100) {
+		logger.Error(fmt.Sprintf("Plan %v doesn't offer %v%% GreenPower", plan.PlanId, *greenPower))
+		os.Exit(1)
+	}
+	household, err := loadHousehold(logger, *householdPath, *accountID, *retailerURL)
 	if err != nil {
 		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	calc := calculator.NewCalculator(logger, quality, *greenPower/100, household)
+	costs, err := calc.CalculateMonthly(nem12Data, plan)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	nmis := make([]string, 0, len(costs))
+	for nmi := range costs {
+		nmis = append(nmis, string(nmi))
+	}
+	sort.Strings(nmis)
+	for _, nmi := range nmis {
+		logCost(logger, "Cost for NMI "+nmi, costs[nem12.NMI(nmi)])
+	}
+	if len(costs) > 1 {
+		logCost(logger, "Combined cost", calculator.CombineCosts(costs))
+	}
+	if *bills {
+		billsByNMI, err := calc.CalculateBills(nem12Data, plan, *billFrequency)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, nmi := range nmis {
+			for _, bill := range billsByNMI[nem12.NMI(nmi)] {
+				logBill(logger, "Bill for NMI "+nmi, bill)
+			}
+		}
+	}
+
+	if *horizon > 0 {
+		horizonCosts, err := calc.CalculateHorizon(nem12Data, plan, time.Now(), *horizon)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, nmi := range nmis {
+			cost, ok := horizonCosts[nem12.NMI(nmi)]
+			if !ok {
+				continue
+			}
+			logger.Info(fmt.Sprintf("Cost for NMI %v over %v months: $%.2f", nmi, *horizon, cost.Total/100),
+				slog.String("firstYear", fmt.Sprintf("$%.2f", cost.FirstYear/100)),
+				slog.String("ongoing", fmt.Sprintf("$%.2f", cost.Ongoing/100)),
+				slog.String("exitFee", fmt.Sprintf("$%.2f", cost.ExitFee/100)),
+				slog.Int("missingDays", cost.MissingDays))
+		}
+	}
+
+	if *intervalCSV != "" {
+		err = writeIntervalCosts(calc, nem12Data, plan, *intervalCSV)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("Wrote interval costs", slog.String("path", *intervalCSV))
+	}
+
+	if *from != "" || *to != "" {
+		fromDate, err := time.Parse(time.DateOnly, *from)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Invalid -from date: %v", err))
+			os.Exit(1)
+		}
+		toDate, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Invalid -to date: %v", err))
+			os.Exit(1)
+		}
+		rangeCosts, err := calc.CalculateRange(nem12Data, plan, fromDate, toDate)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, nmi := range nmis {
+			cost, ok := rangeCosts[nem12.NMI(nmi)]
+			if !ok {
+				continue
+			}
+			logger.Info(fmt.Sprintf("Cost for NMI %v from %v to %v: $%.2f", nmi, *from, *to, cost.Total/100),
+				slog.Int("days", cost.Days),
+				slog.Int("missingDays", cost.MissingDays))
+		}
+	}
+
+	if *gasReadsPath != "" && *gasPlanID == "" && !*dualFuel {
+		logger.Error("-gasplan or -dualfuel must be given with -gasreads")
+		os.Exit(1)
+	}
+	if *dualFuel && *postcode == "" {
+		logger.Error("-postcode must be given with -dualfuel")
+		os.Exit(1)
+	}
+	if *gasPlanID != "" {
+		err = costGas(logger, fetcher, calc, *gasReadsPath, *gasPlanID)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *passThroughPlanID != "" {
+		err = costPassThrough(logger, fetcher, calc, nem12Data, merger.File(), suffixes.mapping, *passThroughPlanID, pricePaths, *region, *lossFactor)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *dualFuel {
+		err = compareDualFuel(logger, calc, nem12Data, *gasReadsPath, *postcode, strings.Split(*retailers, ","))
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Printed once everything has been costed, so the explanation covers every calculation
+	err = printExplanation(calc.Explanation(), *explain)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// Costs the gas reads in the file at path against the gas plan
+func costGas(logger *slog.Logger, fetcher *energyplan.PlanFetcher, calc *calculator.Calculator, path string, planID string) error {
+	reads, err := readGasReads(path)
+	if err != nil {
+		return err
+	}
+	plan, err := fetcher.FetchPlan(planID)
+	if err != nil {
+		return err
+	}
+	cost, err := calc.CalculateGas(reads, plan)
+	if err != nil {
+		return err
+	}
+	for _, bill := range cost.Bills {
+		logBill(logger, "Gas bill", bill)
+	}
+	logger.Info(fmt.Sprintf("Gas cost for %.0f MJ over %v days: $%.2f", cost.MJ, cost.Days, cost.Total/100),
+		slog.String("annualised", fmt.Sprintf("$%.2f", cost.Annualised/100)))
+	return nil
+}
+
+// Costs the usage against a plan passing through the region's spot prices from the files at paths.
+// The usage's intervals come from file, so each one can be charged its own spot price.
+func costPassThrough(logger *slog.Logger, fetcher *energyplan.PlanFetcher, calc *calculator.Calculator, usage nem12.UsageData,
+	file *nem12.File, suffixes *nem12.SuffixMapping, planID string, paths []string, region string, lossFactor float64) error {
+	if len(paths) == 0 || region == "" {
+		return errors.New("-aemoprices and -region must be given with -passthroughplan")
+	}
+	prices, err := aemo.LoadPrices(region, paths...)
+	if err != nil {
+		return err
+	}
+	from, to := prices.Span()
+	logger.Info("Loaded spot prices", slog.String("region", region), slog.Time("from", from), slog.Time("to", to))
+	plan, err := fetcher.FetchPlan(planID)
+	if err != nil {
+		return err
+	}
+	intervals, err := file.IntervalReadings(suffixes, nem12.GeneralUsage)
+	if err != nil {
+		return err
+	}
+	costs, err := calc.CalculatePassThrough(usage, plan, calculator.Wholesale{Prices: prices, LossFactor: lossFactor, Intervals: intervals})
+	if err != nil {
+		return err
+	}
+	nmis := make([]string, 0, len(costs))
+	for nmi := range costs {
+		nmis = append(nmis, string(nmi))
+	}
+	sort.Strings(nmis)
+	for _, nmi := range nmis {
+		logCost(logger, "Pass-through cost for NMI "+nmi, costs[nem12.NMI(nmi)])
+	}
+	if len(costs) > 1 {
+		logCost(logger, "Combined pass-through cost", calculator.CombineCosts(costs))
+	}
+	return nil
+}
+
+func readGasReads(path string) ([]gas.Read, error) {
+	if path == "" {
+		return nil, errors.New("-gasreads must be given to cost gas")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return gas.ParseReads(file)
+}
+
+// Fetches every dual fuel, electricity and gas plan from the retailers and compares the dual fuel
+// plans with the best separate ones
+func compareDualFuel(logger *slog.Logger, calc *calculator.Calculator, usage nem12.UsageData, gasReadsPath string, postcode string, retailers []string) error {
+	reads, err := readGasReads(gasReadsPath)
+	if err != nil {
+		return err
+	}
+	var dualPlans, electricityPlans, gasPlans []*cdsenergy.EnergyPlanDetail
+	for _, retailer := range retailers {
+		fetcher, err := energyplan.NewPlanFetcher(logger, strings.TrimSpace(retailer))
+		if err != nil {
+			return err
+		}
+		plans, err := fetcher.FetchAllPlanDetails(cdsenergy.ListPlansParamsFuelTypeDUAL)
+		if err != nil {
+			return err
+		}
+		dualPlans = append(dualPlans, plans...)
+		plans, err = fetcher.FetchAllPlanDetails(cdsenergy.ListPlansParamsFuelTypeELECTRICITY)
+		if err != nil {
+			return err
+		}
+		electricityPlans = append(electricityPlans, plans...)
+		plans, err = fetcher.FetchAllPlanDetails(cdsenergy.ListPlansParamsFuelTypeGAS)
+		if err != nil {
+			return err
+		}
+		gasPlans = append(gasPlans, plans...)
+	}
+	logger.Info("Fetched plans to compare",
+		slog.Int("dualFuel", len(dualPlans)),
+		slog.Int("electricity", len(electricityPlans)),
+		slog.Int("gas", len(gasPlans)))
+
+	comparison, err := calc.CompareDualFuel(usage, reads, postcode, dualPlans, electricityPlans, gasPlans)
+	if err != nil {
+		return err
+	}
+	for _, cost := range comparison.DualFuel {
+		logFuelCost(logger, "Dual fuel plan "+cost.ElectricityPlan.PlanId, cost)
+	}
+	if comparison.BestSeparate != nil {
+		logFuelCost(logger, fmt.Sprintf("Best separate plans %v and %v",
+			comparison.BestSeparate.ElectricityPlan.PlanId, comparison.BestSeparate.GasPlan.PlanId), *comparison.BestSeparate)
+	}
+	if len(comparison.DualFuel) > 0 && comparison.BestSeparate != nil {
+		logger.Info(fmt.Sprintf("The best dual fuel plan saves $%.2f over the best separate plans", comparison.Saving/100))
+	}
+	logger.Info("Skipped plans that couldn't be costed", slog.Int("skipped", len(comparison.Skipped)))
+	return nil
+}
+
+func logFuelCost(logger *slog.Logger, title string, cost calculator.FuelCost) {
+	logger.Info(fmt.Sprintf("%v: $%.2f", title, cost.Total/100),
+		slog.String("brand", cost.ElectricityPlan.BrandName),
+		slog.String("electricity", fmt.Sprintf("$%.2f", cost.Electricity/100)),
+		slog.String("gas", fmt.Sprintf("$%.2f", cost.Gas/100)))
+}
+
+func logCost(logger *slog.Logger, title string, cost calculator.Cost) {
+	if cost.ImputedShare > 0 {
+		logger.Info(fmt.Sprintf("%v: %.1f%% of readings were imputed to fill gaps", title, cost.ImputedShare*100))
+	}
+	if cost.ActualShare < minActualShare {
+		logger.Warn(fmt.Sprintf("%v: only %.0f%% of readings are actual reads - the cost is largely based on estimates",
+			title, cost.ActualShare*100))
 	}
 
 	var log strings.Builder
+	log.WriteString(title + ": ")
 	for _, c := range cost.AveragePerMonth {
 		log.WriteString(fmt.Sprintf("$%.2f, ", float64(c)/100))
 	}
 	logger.Info(log.String())
+	logger.Info(fmt.Sprintf("%v: $%.2f over a year", title, cost.Annualised/100),
+		slog.Any("extrapolatedMonths", cost.ExtrapolatedMonths))
+}
+
+func logBill(logger *slog.Logger, title string, bill calculator.Bill) {
+	items := make([]string, len(bill.Items))
+	for i, item := range bill.Items {
+		if item.Unit == "" {
+			items[i] = fmt.Sprintf("%v: $%.2f", item.Description, item.Amount/100)
+			continue
+		}
+		items[i] = fmt.Sprintf("%v: %.2f %v at %.2fc = $%.2f", item.Description, item.Quantity, item.Unit, item.Rate, item.Amount/100)
+	}
+	logger.Info(fmt.Sprintf("%v from %v to %v: $%.2f", title,
+		bill.Start.Format(time.DateOnly), bill.End.AddDate(0, 0, -1).Format(time.DateOnly), bill.Total/100),
+		slog.Int("missingDays", bill.MissingDays),
+		slog.Any("items", items))
+}
+
+func printExplanation(explanation *calculator.Explanation, format string) error {
+	switch format {
+	case "":
+		return nil
+	case "text":
+		fmt.Print(explanation.Text())
+		return nil
+	case "json":
+		out, err := explanation.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	default:
+		return fmt.Errorf("unknown explanation format %v", format)
+	}
+}
+
+func writeIntervalCosts(calc *calculator.Calculator, usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail, path string) error {
+	intervals, err := calc.CalculateIntervals(usage, plan)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	err = calculator.WriteIntervalCosts(out, intervals)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// Writes the net series of the merged records. Merging has already resolved days that appear in
+// more than one file, so there's nothing left to reconcile.
+func writeNetSeries(file *nem12.File, suffixes *nem12.SuffixMapping, path string) error {
+	series, err := file.NetSeries(suffixes)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	err = nem12.WriteNetSeries(out, series)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func parseMode(strict bool) nem12.ParseMode {
+	if strict {
+		return nem12.Strict
+	}
+	return nem12.Lenient
+}
+
+func imputationStrategy(name string) (nem12.ImputationStrategy, error) {
+	switch name {
+	case "weekday":
+		return nem12.SameWeekdayAverage{Weeks: 4}, nil
+	case "seasonal":
+		return nem12.SeasonalProfile{}, nil
+	case "interpolate":
+		return nem12.NeighbourInterpolation{}, nil
+	default:
+		return nil, fmt.Errorf("unknown imputation strategy %v", name)
+	}
+}
+
+// Collects every value of a flag that can be given more than once
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// Adds to a suffix mapping each time the flag is given
+type suffixFlag struct {
+	mapping *nem12.SuffixMapping
+}
+
+func (s *suffixFlag) String() string {
+	if s.mapping == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v %v", s.mapping.Suffixes, s.mapping.Prefixes)
+}
+
+func (s *suffixFlag) Set(value string) error {
+	suffix, readingTypeStr, found := strings.Cut(value, "=")
+	if !found || suffix == "" {
+		return fmt.Errorf("expected SUFFIX=TYPE but got %v", value)
+	}
+	readingType, err := nem12.ParseReadingType(readingTypeStr)
+	if err != nil {
+		return err
+	}
+	suffix = strings.ToUpper(suffix)
+	if len(suffix) == 1 {
+		s.mapping.Prefixes[suffix] = readingType
+	} else {
+		s.mapping.Suffixes[suffix] = readingType
+	}
+	return nil
+}
+
+// Builds the household profile from the file at path and the account's concessions, if either is
+// given. Returns nil if neither is.
+func loadHousehold(logger *slog.Logger, path string, accountID string, retailerURL string) (*calculator.Household, error) {
+	if path == "" && accountID == "" {
+		return nil, nil
+	}
+	household := &calculator.Household{}
+	if path != "" {
+		var err error
+		household, err = calculator.LoadHousehold(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if accountID != "" {
+		if retailerURL == "" {
+			return nil, errors.New("-retailerurl must be given with -accountid")
+		}
+		fetcher, err := energyplan.NewConcessionFetcher(logger, retailerURL, os.Getenv("ENKET_ACCESS_TOKEN"))
+		if err != nil {
+			return nil, err
+		}
+		concessions, err := fetcher.FetchConcessions(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fetch concessions: %w", err)
+		}
+		household.Concessions = append(household.Concessions, concessions...)
+	}
+	for _, concession := range household.Concessions {
+		logger.Info("Concession", slog.String("name", concession.DisplayName), slog.String("type", string(concession.Type)))
+	}
+	if len(household.Concessions) > 0 {
+		logger.Warn("Concessions only come off the simulated bills from -bills and -horizon, not the monthly or -from/-to costs")
+	}
+	return household, nil
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+// Rewrites a NEM12 file with all identifying values replaced so it can be shared for debugging
+func redact(ctx context.Context, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("redact", flag.ExitOnError)
+	inPath := flags.String("nem12path", "", "The path to the NEM12 file to redact")
+	outPath := flags.String("out", "", "The path to write the redacted NEM12 file to")
+	strict := flags.Bool("strict", false,
+		"Stop at the first violation of the NEM12 specification. Redaction fails on bad data either way, as it "+
+			"can't be copied without changing it, but without this every problem is logged first.")
+	shiftDays := flags.Int("shiftdays", 0,
+		"The number of days to shift all dates by. Use a multiple of 7 to keep readings on the same day of the week.")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *inPath == "" || *outPath == "" {
+		return errors.New("both a NEM12 path and an output path must be provided")
+	}
+
+	inFile, err := os.Open(*inPath)
+	if err != nil {
+		return fmt.Errorf("could not open NEM12 file: %w", err)
+	}
+	defer inFile.Close()
+
+	parser := nem12.NewParser(logger, inFile, parseMode(*strict), nil)
+	file, err := parser.ParseFile(ctx)
+	if err != nil {
+		return err
+	}
+	// Lenient parsing drops or blanks out bad data, which would quietly leave it out of the redacted
+	// copy
+	err = parser.Report().Lossless()
+	if err != nil {
+		return fmt.Errorf("the NEM12 file has bad data that couldn't be redacted without changing it, as logged "+
+			"above: %w", err)
+	}
+	nem12.NewRedactor(*shiftDays).Redact(file)
+
+	outFile, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	defer outFile.Close()
+	return nem12.NewWriter(outFile).WriteFile(file)
+}
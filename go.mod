@@ -1,6 +1,6 @@
 module github.com/georgesolomos/enket
 
-go 1.21
+go 1.25
 
 require golang.org/x/exp v0.0.0-20231006140011-7918f672742d
 
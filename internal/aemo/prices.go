@@ -0,0 +1,232 @@
+package aemo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The wholesale spot price for a region over a dispatch or trading interval. Times are in market
+// time (AEST all year), like NEM12 interval times.
+type Price struct {
+	Region string
+	Start  time.Time
+	// AEMO's settlement date, which is the end of the interval
+	End time.Time
+	// The regional reference price in $/MWh. It can be negative.
+	RRP float64
+}
+
+// Spot prices for a single region, sorted and without overlaps
+type Prices struct {
+	Region string
+	prices []Price
+}
+
+// Without at least two prices in a file, we can't tell how long its intervals are, so we assume
+// they're dispatch intervals
+const defaultInterval = 5 * time.Minute
+
+// The format AEMO uses for settlement dates
+const settlementDateFormat = "2006/01/02 15:04:05"
+
+// Loads the region's prices from AEMO CSV files. Each path can be a file or a directory of them.
+// Both the aggregated price and demand files (e.g. PRICE_AND_DEMAND_202301_NSW1.csv) and MMS
+// dispatch and trading files with a PRICE table are supported. Where files cover the same interval,
+// the one loaded first is kept.
+func LoadPrices(region string, paths ...string) (*Prices, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.[cC][sS][vV]"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	var all []Price
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		prices, err := ParsePrices(f, region)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %v: %w", file, err)
+		}
+		all = append(all, prices...)
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no prices found for region %v", region)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Start.Before(all[j].Start)
+	})
+	merged := make([]Price, 0, len(all))
+	for _, price := range all {
+		if len(merged) > 0 && price.Start.Before(merged[len(merged)-1].End) {
+			continue
+		}
+		merged = append(merged, price)
+	}
+	return &Prices{Region: region, prices: merged}, nil
+}
+
+// Parses the region's prices from an AEMO CSV file. Intervention pricing runs are skipped, as
+// they aren't what's settled. The length of the file's intervals is the shortest gap between its
+// settlement dates.
+func ParsePrices(r io.Reader, region string) ([]Price, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	var columns map[string]int
+	var prices []Price
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case record[0] == "C":
+			// MMS comment and end of report rows
+			continue
+		case record[0] == "I":
+			// MMS header rows describe the D rows after them, and we only want the PRICE table
+			columns = nil
+			if len(record) > 2 && record[2] == "PRICE" {
+				columns = indexColumns(record)
+			}
+			continue
+		case columns == nil:
+			for _, field := range record {
+				if field == "SETTLEMENTDATE" {
+					columns = indexColumns(record)
+				}
+			}
+			continue
+		}
+
+		price, ok, err := parsePrice(record, columns, region)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			prices = append(prices, price)
+		}
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].End.Before(prices[j].End)
+	})
+	interval := defaultInterval
+	if len(prices) > 1 {
+		interval = 0
+		for i := 1; i < len(prices); i++ {
+			gap := prices[i].End.Sub(prices[i-1].End)
+			if gap > 0 && (interval == 0 || gap < interval) {
+				interval = gap
+			}
+		}
+	}
+	for i := range prices {
+		prices[i].Start = prices[i].End.Add(-interval)
+	}
+	return prices, nil
+}
+
+func indexColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	return columns
+}
+
+// Parses a row of prices. Returns false if it's for another region or an intervention run.
+func parsePrice(record []string, columns map[string]int, region string) (Price, bool, error) {
+	field := func(names ...string) (string, bool) {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i]), true
+			}
+		}
+		return "", false
+	}
+	rowRegion, ok := field("REGIONID", "REGION")
+	if !ok {
+		return Price{}, false, errors.New("price row has no region")
+	}
+	if rowRegion != region {
+		return Price{}, false, nil
+	}
+	if intervention, ok := field("INTERVENTION"); ok && intervention == "1" {
+		return Price{}, false, nil
+	}
+	settlementDate, ok := field("SETTLEMENTDATE")
+	if !ok {
+		return Price{}, false, errors.New("price row has no settlement date")
+	}
+	end, err := time.Parse(settlementDateFormat, settlementDate)
+	if err != nil {
+		return Price{}, false, fmt.Errorf("couldn't parse settlement date: %w", err)
+	}
+	rrpField, ok := field("RRP")
+	if !ok {
+		return Price{}, false, errors.New("price row has no RRP")
+	}
+	rrp, err := strconv.ParseFloat(rrpField, 64)
+	if err != nil {
+		return Price{}, false, fmt.Errorf("couldn't parse RRP: %w", err)
+	}
+	return Price{Region: rowRegion, End: end, RRP: rrp}, true, nil
+}
+
+// Gets the first and last times the prices cover
+func (p *Prices) Span() (time.Time, time.Time) {
+	return p.prices[0].Start, p.prices[len(p.prices)-1].End
+}
+
+// Works out the average price in $/MWh from start up to end, weighted by how much of the time each
+// interval covers. Returns false if the prices don't cover all of it.
+func (p *Prices) Average(start, end time.Time) (float64, bool) {
+	i := sort.Search(len(p.prices), func(i int) bool {
+		return p.prices[i].End.After(start)
+	})
+	covered := time.Duration(0)
+	weighted := 0.0
+	for ; i < len(p.prices) && p.prices[i].Start.Before(end); i++ {
+		from := p.prices[i].Start
+		if from.Before(start) {
+			from = start
+		}
+		to := p.prices[i].End
+		if to.After(end) {
+			to = end
+		}
+		covered = covered + to.Sub(from)
+		weighted = weighted + p.prices[i].RRP*to.Sub(from).Hours()
+	}
+	if covered < end.Sub(start) {
+		return 0, false
+	}
+	return weighted / end.Sub(start).Hours(), true
+}
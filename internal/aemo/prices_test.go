@@ -0,0 +1,152 @@
+package aemo
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// An aggregated price and demand file of 30 minute trading intervals
+const tradingFile = `REGION,SETTLEMENTDATE,TOTALDEMAND,RRP,PERIODTYPE
+NSW1,2023/01/01 00:30:00,7000.5,80,TRADE
+VIC1,2023/01/01 00:30:00,5000.5,60,TRADE
+NSW1,2023/01/01 01:00:00,7100.5,-20.5,TRADE
+NSW1,2023/01/01 01:30:00,7200.5,100,TRADE
+`
+
+// An MMS dispatch file of 5 minute intervals. The second interval has an intervention run too.
+const dispatchFile = `C,NEMP.WORLD,DISPATCHIS,AEMO,PUBLIC,2023/01/01,00:05:00,0000000381111111,DISPATCHIS,0000000381111111
+I,DISPATCH,CASE_SOLUTION,1,SETTLEMENTDATE,RUNNO,INTERVENTION
+D,DISPATCH,CASE_SOLUTION,1,"2023/01/01 00:05:00",1,0
+I,DISPATCH,PRICE,5,SETTLEMENTDATE,RUNNO,REGIONID,DISPATCHINTERVAL,INTERVENTION,RRP,EEP
+D,DISPATCH,PRICE,5,"2023/01/01 00:05:00",1,NSW1,20230101001,0,50,0
+D,DISPATCH,PRICE,5,"2023/01/01 00:05:00",1,QLD1,20230101001,0,40,0
+D,DISPATCH,PRICE,5,"2023/01/01 00:10:00",1,NSW1,20230101002,0,70,0
+D,DISPATCH,PRICE,5,"2023/01/01 00:10:00",1,NSW1,20230101002,1,9000,0
+D,DISPATCH,PRICE,5,"2023/01/01 00:15:00",1,NSW1,20230101003,0,90,0
+C,"END OF REPORT",9
+`
+
+func at(hour, minute int) time.Time {
+	return time.Date(2023, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParsePricesTrading(t *testing.T) {
+	prices, err := ParsePrices(strings.NewReader(tradingFile), "NSW1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Price{
+		{Region: "NSW1", Start: at(0, 0), End: at(0, 30), RRP: 80},
+		{Region: "NSW1", Start: at(0, 30), End: at(1, 0), RRP: -20.5},
+		{Region: "NSW1", Start: at(1, 0), End: at(1, 30), RRP: 100},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("got %+v, want %+v", prices, want)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Errorf("got %+v, want %+v", prices[i], want[i])
+		}
+	}
+}
+
+func TestParsePricesDispatch(t *testing.T) {
+	prices, err := ParsePrices(strings.NewReader(dispatchFile), "NSW1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the PRICE table is read, and the intervention run is left out
+	want := []Price{
+		{Region: "NSW1", Start: at(0, 0), End: at(0, 5), RRP: 50},
+		{Region: "NSW1", Start: at(0, 5), End: at(0, 10), RRP: 70},
+		{Region: "NSW1", Start: at(0, 10), End: at(0, 15), RRP: 90},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("got %+v, want %+v", prices, want)
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Errorf("got %+v, want %+v", prices[i], want[i])
+		}
+	}
+}
+
+func TestParsePricesIntervalLength(t *testing.T) {
+	// A missing interval doesn't make the others look longer than they are
+	file := `REGION,SETTLEMENTDATE,TOTALDEMAND,RRP,PERIODTYPE
+SA1,2023/01/01 02:00:00,1000,30,TRADE
+SA1,2023/01/01 00:30:00,1000,10,TRADE
+SA1,2023/01/01 01:00:00,1000,20,TRADE
+`
+	prices, err := ParsePrices(strings.NewReader(file), "SA1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 3 || !prices[2].Start.Equal(at(1, 30)) || !prices[0].Start.Equal(at(0, 0)) {
+		t.Errorf("got %+v, want sorted 30 minute intervals", prices)
+	}
+
+	// With only one price there's nothing to go on, so it's taken as a dispatch interval
+	prices, err = ParsePrices(strings.NewReader("REGION,SETTLEMENTDATE,TOTALDEMAND,RRP,PERIODTYPE\nSA1,2023/01/01 00:30:00,1000,10,TRADE\n"), "SA1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 1 || !prices[0].Start.Equal(at(0, 25)) {
+		t.Errorf("got %+v, want a 5 minute interval", prices)
+	}
+}
+
+func TestParsePricesErrors(t *testing.T) {
+	for name, file := range map[string]string{
+		"bad RRP":             "REGION,SETTLEMENTDATE,RRP\nNSW1,2023/01/01 00:30:00,cheap\n",
+		"bad settlement date": "REGION,SETTLEMENTDATE,RRP\nNSW1,01/01/2023 00:30,10\n",
+		"no RRP":              "REGION,SETTLEMENTDATE,TOTALDEMAND\nNSW1,2023/01/01 00:30:00,1000\n",
+		"no region":           "SETTLEMENTDATE,RRP\n2023/01/01 00:30:00,10\n",
+	} {
+		_, err := ParsePrices(strings.NewReader(file), "NSW1")
+		if err == nil {
+			t.Errorf("%v: accepted the file", name)
+		}
+	}
+}
+
+func TestAverage(t *testing.T) {
+	parsed, err := ParsePrices(strings.NewReader(tradingFile), "NSW1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Take out the middle interval to leave a gap
+	gappy := &Prices{Region: "NSW1", prices: []Price{parsed[0], parsed[2]}}
+	prices := &Prices{Region: "NSW1", prices: parsed}
+
+	tests := []struct {
+		name       string
+		prices     *Prices
+		start, end time.Time
+		want       float64
+		ok         bool
+	}{
+		{"one whole interval", prices, at(0, 0), at(0, 30), 80, true},
+		{"part of an interval", prices, at(0, 35), at(0, 40), -20.5, true},
+		{"weighted across intervals", prices, at(0, 15), at(1, 0), (80*15 - 20.5*30) / 45.0, true},
+		{"every interval", prices, at(0, 0), at(1, 30), (80 - 20.5 + 100) / 3, true},
+		{"before the first price", prices, time.Date(2022, 12, 31, 23, 30, 0, 0, time.UTC), at(0, 30), 0, false},
+		{"after the last price", prices, at(1, 0), at(2, 0), 0, false},
+		{"over a missing interval", gappy, at(0, 0), at(1, 30), 0, false},
+		{"inside a missing interval", gappy, at(0, 35), at(0, 40), 0, false},
+		{"after a missing interval", gappy, at(1, 0), at(1, 30), 100, true},
+	}
+	for _, test := range tests {
+		got, ok := test.prices.Average(test.start, test.end)
+		if ok != test.ok || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: got %v, %v, want %v, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+
+	from, to := prices.Span()
+	if !from.Equal(at(0, 0)) || !to.Equal(at(1, 30)) {
+		t.Errorf("got a span from %v to %v", from, to)
+	}
+}
@@ -0,0 +1,260 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// A simulated bill, as the retailer would issue it for the usage data
+type Bill struct {
+	NMI nem12.NMI
+	// The first day of the bill
+	Start time.Time
+	// The day after the last day of the bill
+	End time.Time
+	// Number of days in the bill without usage data to charge. The last bill will usually have
+	// some, since the usage data rarely ends on a bill boundary.
+	MissingDays int
+	// Charges in the order they'd appear on the bill, with discounts and then concessions last
+	Items []LineItem
+	Total float64
+}
+
+// A single charge on a bill. Discounts and credits have a negative amount.
+type LineItem struct {
+	Description string
+	// How much of Unit was charged, e.g. days of supply or kWh of usage. Zero for items that are a
+	// single amount, such as a percentage discount.
+	Quantity float64
+	Unit     string
+	// The price per unit, including GST
+	Rate float64
+	// The amount charged, including GST
+	Amount float64
+}
+
+// Simulates the sequence of bills for each NMI's usage, keyed by NMI. Bills are issued every
+// frequency, which is an ISO 8601 duration such as P1M or P3M, starting from the first day of usage.
+// If frequency is empty, the plan's first bill frequency is used. The plan's discounts are applied
+// to each bill, with conditional discounts (e.g. for paying on time) assumed to be met.
+func (c *Calculator) CalculateBills(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail, frequency string) (map[nem12.NMI][]Bill, error) {
+	c.explanation = &Explanation{}
+	if frequency == "" {
+		if len(plan.ElectricityContract.BillFrequency) == 0 {
+			return nil, errors.New("plan has no bill frequency")
+		}
+		frequency = plan.ElectricityContract.BillFrequency[0]
+	}
+	every, err := util.ParsePeriod(frequency)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse bill frequency: %w", err)
+	}
+
+	bills := make(map[nem12.NMI][]Bill, len(usage))
+	for _, nmi := range sortedNMIs(usage) {
+		readings := c.applyQualityPolicy(nmi, usage[nmi][nem12.GeneralUsage])
+		if len(readings) == 0 {
+			continue
+		}
+		c.explain(nmi, BillStep, "Billing every %v from %v", frequency, readings[0].StartTime.Format(time.DateOnly))
+		// Bill up to the end of the bill the last reading falls in
+		from := util.StartOfDay(readings[0].StartTime)
+		to := from
+		for !to.After(readings[len(readings)-1].StartTime) {
+			to = every.AddTo(to)
+		}
+		bills[nmi], err = c.simulateBills(nmi, readings, plan, every, from, to, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+	}
+	if len(bills) == 0 {
+		return nil, errors.New("no general usage readings to cost")
+	}
+	return bills, nil
+}
+
+// Issues a bill every period from the from day up to the to day, cutting the last bill short if it
+// would go past it. Discounts stop applying to bills that start after benefitsEnd, unless it's zero.
+func (c *Calculator) simulateBills(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail, every util.Period, from, to, benefitsEnd time.Time) ([]Bill, error) {
+	var bills []Bill
+	for start := from; start.Before(to); start = every.AddTo(start) {
+		end := every.AddTo(start)
+		if end.After(to) {
+			end = to
+		}
+		benefits := benefitsEnd.IsZero() || start.Before(benefitsEnd)
+		bill, err := c.calculateBill(nmi, readings, plan, start, end, benefits)
+		if err != nil {
+			return nil, err
+		}
+		bills = append(bills, bill)
+	}
+	return bills, nil
+}
+
+// Works out a single bill. If benefits is false, the plan's discounts aren't applied. The
+// household's concessions always are, as they don't depend on the plan.
+func (c *Calculator) calculateBill(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail, start, end time.Time, benefits bool) (Bill, error) {
+	bill := Bill{NMI: nmi, Start: start, End: end}
+	days, err := c.costDays(nmi, readingsBetween(readings, start, end), plan, &billPeriod{start: start, end: end})
+	if err != nil {
+		return bill, err
+	}
+	bill.MissingDays = daysBetween(start, end) - len(days)
+	if bill.MissingDays > 0 {
+		c.explain(nmi, BillStep, "Bill from %v to %v has %v days without usage data, which aren't charged",
+			start.Format(time.DateOnly), end.Format(time.DateOnly), bill.MissingDays)
+	}
+
+	// Group the intervals into line items with the same charge at the same rate. GreenPower goes
+	// after the usage charges, as it's a charge on top of them.
+	type itemKey struct {
+		tariffPeriod string
+		component    string
+		rate         float64
+	}
+	items := make(map[itemKey]*LineItem)
+	var charges, greenPower []itemKey
+	supply := 0.0
+	usageCharges := 0.0
+	for _, day := range days {
+		supply = supply + day.supply
+		usageCharges = usageCharges + day.usage
+		for _, interval := range day.intervals {
+			key := itemKey{interval.TariffPeriod, interval.Component, interval.Rate}
+			item, ok := items[key]
+			if !ok {
+				item = &LineItem{Unit: interval.unit}
+				if interval.unit != "" {
+					item.Rate = interval.Rate
+				}
+				switch interval.Component {
+				case SupplyComponent:
+					item.Description = fmt.Sprintf("Daily supply charge (%v)", interval.TariffPeriod)
+					charges = append(charges, key)
+				case GreenPowerComponent:
+					item.Description = fmt.Sprintf("GreenPower (%v)", interval.TariffPeriod)
+					greenPower = append(greenPower, key)
+				default:
+					item.Description = fmt.Sprintf("Usage %v (%v)", interval.Component, interval.TariffPeriod)
+					charges = append(charges, key)
+				}
+				items[key] = item
+			}
+			switch interval.unit {
+			case "days":
+				item.Quantity = item.Quantity + 1
+			case "kWh":
+				item.Quantity = item.Quantity + interval.EnergyKWh
+			}
+			item.Amount = item.Amount + interval.Cost
+		}
+	}
+	for _, key := range append(charges, greenPower...) {
+		bill.Items = append(bill.Items, *items[key])
+	}
+
+	if benefits {
+		discounts, err := c.billDiscounts(nmi, plan.ElectricityContract, start, supply, usageCharges)
+		if err != nil {
+			return bill, err
+		}
+		bill.Items = append(bill.Items, discounts...)
+	}
+	// Concessions come off what's left once the plan's discounts are taken off
+	invoice := 0.0
+	for _, item := range bill.Items {
+		invoice = invoice + item.Amount
+	}
+	concessions, err := c.billConcessions(nmi, start, end, billCharges{supply: supply, usage: usageCharges, invoice: invoice})
+	if err != nil {
+		return bill, err
+	}
+	bill.Items = append(bill.Items, concessions...)
+	for _, item := range bill.Items {
+		bill.Total = bill.Total + item.Amount
+	}
+	return bill, nil
+}
+
+// Works out the contract's discounts on a bill starting on the given day with the given supply and
+// usage charges. Discounts with an end date before the bill starts aren't applied.
+func (c *Calculator) billDiscounts(nmi nem12.NMI, contract *cdsenergy.EnergyPlanContractFull, start time.Time, supply, usage float64) ([]LineItem, error) {
+	if contract.Discounts == nil {
+		return nil, nil
+	}
+	var items []LineItem
+	for _, discount := range *contract.Discounts {
+		if discount.EndDate != nil {
+			endDate, err := time.Parse(time.DateOnly, *discount.EndDate)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse discount end date: %w", err)
+			}
+			if endDate.Before(start) {
+				c.explain(nmi, DiscountStep, "Not applied: %v ended on %v", discount.DisplayName, *discount.EndDate)
+				continue
+			}
+		}
+		description := discount.DisplayName
+		if discount.Type == cdsenergy.EnergyPlanContractFullDiscountsTypeCONDITIONAL {
+			description = description + " (conditional)"
+		}
+		var amount float64
+		var err error
+		switch discount.MethodUType {
+		case cdsenergy.EnergyPlanContractFullDiscountsMethodUTypePercentOfBill:
+			if discount.PercentOfBill == nil {
+				return nil, fmt.Errorf("discount %v has no percentOfBill", discount.DisplayName)
+			}
+			amount, err = parseDiscountNumber(discount.PercentOfBill.Rate)
+			amount = amount * (supply + usage)
+		case cdsenergy.EnergyPlanContractFullDiscountsMethodUTypePercentOfUse:
+			if discount.PercentOfUse == nil {
+				return nil, fmt.Errorf("discount %v has no percentOfUse", discount.DisplayName)
+			}
+			amount, err = parseDiscountNumber(discount.PercentOfUse.Rate)
+			amount = amount * usage
+		case cdsenergy.EnergyPlanContractFullDiscountsMethodUTypeFixedAmount:
+			if discount.FixedAmount == nil {
+				return nil, fmt.Errorf("discount %v has no fixedAmount", discount.DisplayName)
+			}
+			amount, err = parseDiscountNumber(discount.FixedAmount.Amount)
+			amount = util.WithGST(amount)
+		case cdsenergy.EnergyPlanContractFullDiscountsMethodUTypePercentOverThreshold:
+			if discount.PercentOverThreshold == nil {
+				return nil, fmt.Errorf("discount %v has no percentOverThreshold", discount.DisplayName)
+			}
+			var threshold float64
+			threshold, err = parseDiscountNumber(discount.PercentOverThreshold.UsageAmount)
+			if err != nil {
+				return nil, err
+			}
+			amount, err = parseDiscountNumber(discount.PercentOverThreshold.Rate)
+			amount = amount * max(usage-threshold, 0)
+		default:
+			c.explain(nmi, DiscountStep, "Not applied: %v has an unsupported method %v", discount.DisplayName, discount.MethodUType)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.explain(nmi, DiscountStep, "Applied %v (%v) for %.2f", description, discount.MethodUType, amount)
+		items = append(items, LineItem{Description: description, Amount: -amount})
+	}
+	return items, nil
+}
+
+func parseDiscountNumber(value string) (float64, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse discount: %w", err)
+	}
+	return number, nil
+}
@@ -0,0 +1,167 @@
+package calculator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+func TestCalculateBillsWithDiscountsAndConcessions(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), 1)
+	plan := singleRatePlan(t, `
+		"discounts": [{
+			"displayName": "10% off",
+			"type": "CONDITIONAL",
+			"methodUType": "percentOfBill",
+			"percentOfBill": {"rate": "0.1"}
+		}]`)
+	// A rebate of 3650 a year comes to 10 a day
+	household := &Household{}
+	err := json.Unmarshal([]byte(`{"concessions": [{
+		"displayName": "Energy rebate",
+		"type": "FIXED_AMOUNT",
+		"amount": "3650",
+		"discountFrequency": "P1Y"
+	}]}`), household)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, household)
+
+	bills, err := calc.CalculateBills(usage, plan, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nmiBills := bills["NMI1234567"]
+	if len(nmiBills) != 3 {
+		t.Fatalf("got %v bills, want one for each month", len(nmiBills))
+	}
+	if !nmiBills[2].Start.Equal(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)) ||
+		!nmiBills[2].End.Equal(time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)) || nmiBills[2].MissingDays != 17 {
+		t.Errorf("got a last bill from %v to %v with %v missing days", nmiBills[2].Start, nmiBills[2].End, nmiBills[2].MissingDays)
+	}
+
+	january := nmiBills[0]
+	descriptions := make([]string, 0, len(january.Items))
+	for _, item := range january.Items {
+		descriptions = append(descriptions, item.Description)
+	}
+	want := []string{"Daily supply charge (All year)", "Usage block 1 (All year)", "10% off (conditional)", "Concession: Energy rebate"}
+	if len(descriptions) != len(want) {
+		t.Fatalf("got items %v, want %v", descriptions, want)
+	}
+	for i := range want {
+		if descriptions[i] != want[i] {
+			t.Errorf("got items %v, want %v", descriptions, want)
+			break
+		}
+	}
+	charges := 31*110 + 31*24*11.0
+	if !closeTo(january.Items[2].Amount, -0.1*charges) || !closeTo(january.Items[3].Amount, -310) {
+		t.Errorf("got a discount of %v and a concession of %v", january.Items[2].Amount, january.Items[3].Amount)
+	}
+	if !closeTo(january.Total, 0.9*charges-310) {
+		t.Errorf("got a total of %v, want %v", january.Total, 0.9*charges-310)
+	}
+}
+
+func TestCalculateBillsBuildsUpBlocksOverTheBill(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), 1)
+	plan := singleRatePlan(t, "")
+	// The first 900 kWh a quarter at 10 and the rest at 20. The volume sits between two readings so
+	// it doesn't matter how a reading that crosses it is charged.
+	err := json.Unmarshal([]byte(`{
+		"displayName": "Usage",
+		"period": "P3M",
+		"rates": [{"unitPrice": "10", "volume": 900.5}, {"unitPrice": "20"}]
+	}`), plan.ElectricityContract.TariffPeriod[0].SingleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	bills, err := calc.CalculateBills(usage, plan, "P3M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nmiBills := bills["NMI1234567"]
+	if len(nmiBills) != 1 {
+		t.Fatalf("got %v bills, want 1", len(nmiBills))
+	}
+	blocks := make(map[string]LineItem)
+	for _, item := range nmiBills[0].Items {
+		blocks[item.Description] = item
+	}
+	first := blocks["Usage block 1 (All year)"]
+	second := blocks["Usage block 2 (All year)"]
+	if first.Quantity != 900 || second.Quantity != 90*24-900 {
+		t.Errorf("charged %v kWh in block 1 and %v in block 2, want 900 and %v", first.Quantity, second.Quantity, 90*24-900)
+	}
+	if !closeTo(first.Rate, 11) || !closeTo(second.Rate, 22) {
+		t.Errorf("got rates of %v and %v, want 11 and 22 including GST", first.Rate, second.Rate)
+	}
+	explained := false
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == BlockStep && strings.Contains(step.Text, "block 1 (usage up to 900.5 kWh a quarter at 10 plus GST)") {
+			explained = true
+		}
+	}
+	if !explained {
+		t.Errorf("the explanation doesn't give the block volume per quarter: %v", calc.Explanation().Text())
+	}
+}
+
+func TestDescribePeriod(t *testing.T) {
+	for duration, want := range map[string]string{
+		"P1D":   "a day",
+		"P1W":   "a week",
+		"P1M":   "a month",
+		"P3M":   "a quarter",
+		"P1Y":   "a year",
+		"P2M":   "every 2 months",
+		"P1Y6M": "every 1 year and 6 months",
+	} {
+		period, err := util.ParsePeriod(duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := describePeriod(period); got != want {
+			t.Errorf("got %q for %v, want %q", got, duration, want)
+		}
+	}
+}
+
+func TestConcessionsOnlyComeOffBills(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+	household := &Household{}
+	err := json.Unmarshal([]byte(`{"concessions": [{
+		"displayName": "Energy rebate",
+		"type": "FIXED_AMOUNT",
+		"amount": "3650",
+		"discountFrequency": "P1Y"
+	}]}`), household)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, household)
+
+	costs, err := calc.CalculateMonthly(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 31*110 + 31*24*11.0; !closeTo(costs["NMI1234567"].AveragePerMonth[0], want) {
+		t.Errorf("got %v for January, want %v before concessions", costs["NMI1234567"].AveragePerMonth[0], want)
+	}
+	explained := false
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == ConcessionStep && strings.HasPrefix(step.Text, "Not applied: Energy rebate") {
+			explained = true
+		}
+	}
+	if !explained {
+		t.Errorf("the explanation doesn't say the concession wasn't applied")
+	}
+}
@@ -4,19 +4,39 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"strconv"
+	"sort"
 	"time"
 
 	"github.com/georgesolomos/enket/api/cdsenergy"
 	"github.com/georgesolomos/enket/internal/nem12"
 	"github.com/georgesolomos/enket/internal/util"
-	"golang.org/x/exp/maps"
 )
 
 type Calculator struct {
-	logger *slog.Logger
+	logger  *slog.Logger
+	quality QualityPolicy
+	// The share of GreenPower to cost, from 0 to 1
+	greenPower float64
+	// Concessions and rebates to apply to bills. May be nil.
+	household *Household
+	// Spot prices to pass through while costing a pass-through plan. Nil otherwise.
+	wholesale *Wholesale
+	// How the most recent calculation was done
+	explanation *Explanation
 }
 
+// Determines how readings that aren't actual reads (i.e. estimates and substitutions) are costed
+type QualityPolicy int
+
+const (
+	// Costs every reading. The share of actual reads is still reported so estimates can be flagged.
+	IncludeEstimates QualityPolicy = iota
+	// Leaves out every day containing a reading that isn't an actual read. The missing days are
+	// then extrapolated in the same way as any other missing data. Readings imputed to fill gaps
+	// are kept, since they were asked for and the meter never estimated them.
+	ActualOnly
+)
+
 type Cost struct {
 	// This will always be populated and will be an average monthly cost given all the usage data available
 	AverageMonthly float64
@@ -24,157 +44,268 @@ type Cost struct {
 	// this is populated depends on the usage data provided. If there is at least a year of usage,
 	// all indices will have a value.
 	AveragePerMonth []float64
+	// The share of readings that were actual reads rather than estimates or substitutions, from 0
+	// to 1. A cost based on a low share rests largely on estimates.
+	ActualShare float64
+	// ActualShare broken down by month, indexed like AveragePerMonth. Months without readings are 0.
+	ActualSharePerMonth []float64
+	// The share of readings that weren't in the usage data and were imputed to fill a gap, from 0 to 1
+	ImputedShare float64
+	// An estimate of the cost of a full year. Months without enough data are filled in based on
+	// the months that have it, so this can be compared between users with different amounts of data.
+	Annualised float64
+	// The months that had to be filled in to work out Annualised
+	ExtrapolatedMonths []time.Month
+	// Number of readings costed in each month, indexed like AveragePerMonth. Used to weight the
+	// shares when costs are combined.
+	readingsPerMonth []int
+	imputedReadings  int
 }
 
-func NewCalculator(logger *slog.Logger) *Calculator {
+// Creates a calculator. greenPower is the share of GreenPower to buy, from 0 to 1, using the tiers of
+// each plan's GreenPower charges. Plans that can't provide it fail with ErrGreenPowerUnavailable.
+// household's concessions are applied to simulated bills, and can be nil if there aren't any.
+func NewCalculator(logger *slog.Logger, quality QualityPolicy, greenPower float64, household *Household) *Calculator {
 	return &Calculator{
-		logger: logger,
+		logger:      logger,
+		quality:     quality,
+		greenPower:  greenPower,
+		household:   household,
+		explanation: &Explanation{},
 	}
 }
 
-func (c *Calculator) CalculateMonthly(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) (Cost, error) {
-	switch plan.ElectricityContract.PricingModel {
-	case cdsenergy.EnergyPlanContractFullPricingModelSINGLERATE, cdsenergy.EnergyPlanContractFullPricingModelSINGLERATECONTLOAD:
-		return c.calculateSingleRate(usage, plan)
-	case cdsenergy.EnergyPlanContractFullPricingModelTIMEOFUSE, cdsenergy.EnergyPlanContractFullPricingModelTIMEOFUSECONTLOAD:
-		return c.calculateTimeOfUse(usage, plan)
-	default:
-		return Cost{}, fmt.Errorf("unsupported pricing model %v", plan.ElectricityContract.PricingModel)
+// Gets an audit trail of how the most recent calculation was done
+func (c *Calculator) Explanation() *Explanation {
+	return c.explanation
+}
+
+// Costs each NMI in the usage data independently against the plan, keyed by NMI. NMIs without any
+// general usage readings are left out. Use CombineCosts to get a total across all of them. The
+// household's concessions aren't applied, as they depend on how the usage is billed.
+func (c *Calculator) CalculateMonthly(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) (map[nem12.NMI]Cost, error) {
+	c.explanation = &Explanation{}
+	c.explainDiscounts(plan)
+	c.explainConcessions()
+	costs := make(map[nem12.NMI]Cost, len(usage))
+	for _, nmi := range sortedNMIs(usage) {
+		readings := usage[nmi][nem12.GeneralUsage]
+		if len(readings) == 0 {
+			c.logger.Info("No general usage readings for NMI, skipping it", slog.String("nmi", string(nmi)))
+			continue
+		}
+		cost, err := c.calculateNMI(nmi, readings, plan)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		costs[nmi] = cost
+	}
+	if len(costs) == 0 {
+		return nil, errors.New("no general usage readings to cost")
+	}
+	return costs, nil
+}
+
+// Adds up the costs of several NMIs, e.g. a house and a granny flat on separate meters. Each month
+// is the sum of the NMIs that have a cost for it, and the average monthly cost is the sum of their
+// averages, and likewise for the annualised cost. A month is extrapolated if it was for any of the
+// NMIs. The actual and imputed shares are weighted by the number of readings behind each cost.
+func CombineCosts(costs map[nem12.NMI]Cost) Cost {
+	combined := Cost{
+		AveragePerMonth:     make([]float64, 12),
+		ActualSharePerMonth: make([]float64, 12),
+		readingsPerMonth:    make([]int, 12),
+	}
+	actualPerMonth := make([]float64, 12)
+	extrapolated := make(map[time.Month]bool)
+	for _, cost := range costs {
+		combined.AverageMonthly = combined.AverageMonthly + cost.AverageMonthly
+		combined.Annualised = combined.Annualised + cost.Annualised
+		for _, month := range cost.ExtrapolatedMonths {
+			extrapolated[month] = true
+		}
+		combined.imputedReadings = combined.imputedReadings + cost.imputedReadings
+		for i := range combined.AveragePerMonth {
+			combined.AveragePerMonth[i] = combined.AveragePerMonth[i] + cost.AveragePerMonth[i]
+			combined.readingsPerMonth[i] = combined.readingsPerMonth[i] + cost.readingsPerMonth[i]
+			actualPerMonth[i] = actualPerMonth[i] + cost.ActualSharePerMonth[i]*float64(cost.readingsPerMonth[i])
+		}
+	}
+	for month := time.January; month <= time.December; month++ {
+		if extrapolated[month] {
+			combined.ExtrapolatedMonths = append(combined.ExtrapolatedMonths, month)
+		}
+	}
+	allActual := 0.0
+	allTotal := 0
+	for i, total := range combined.readingsPerMonth {
+		if total != 0 {
+			combined.ActualSharePerMonth[i] = actualPerMonth[i] / float64(total)
+		}
+		allActual = allActual + actualPerMonth[i]
+		allTotal = allTotal + total
+	}
+	if allTotal != 0 {
+		combined.ActualShare = allActual / float64(allTotal)
+		combined.ImputedShare = float64(combined.imputedReadings) / float64(allTotal)
 	}
+	return combined
 }
 
-func (c *Calculator) calculateSingleRate(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) (Cost, error) {
+func (c *Calculator) calculateNMI(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail) (Cost, error) {
 	cost := Cost{
-		AverageMonthly:  0,
-		AveragePerMonth: make([]float64, 12),
+		AverageMonthly:      0,
+		AveragePerMonth:     make([]float64, 12),
+		ActualSharePerMonth: make([]float64, 12),
 	}
+	readings = c.applyQualityPolicy(nmi, readings)
+	c.setDataQuality(&cost, readings)
+	days, err := c.costDays(nmi, readings, plan, nil)
+	if err != nil {
+		return cost, err
+	}
+
 	monthlyTotals := make([]float64, 12)
 	monthlyReadings := make([]int, 12)
-	for _, tariff := range plan.ElectricityContract.TariffPeriod {
-		start, err := time.Parse("01-02", tariff.StartDate)
-		if err != nil {
-			return cost, err
+	// The month we're currently adding days to, identified by its first day
+	var month time.Time
+	monthlyCharge := 0.0
+	daysThisMonth := 0
+	// Adds the current month to the monthly totals. You would call this once there are no more
+	// days to add to it.
+	finaliseMonth := func() {
+		if daysThisMonth == 0 {
+			return
 		}
-		end, err := time.Parse("01-02", tariff.EndDate)
-		if err != nil {
-			return cost, err
-		}
-		nmis := maps.Keys(usage)
-		selectedNmi := nmis[0]
-		if len(nmis) > 1 {
-			c.logger.Warn("More than 1 NMI detected - the one with the most readings will be used")
-			maxReadings := 0
-			for _, nmi := range nmis {
-				count := len(usage[nmi])
-				if count > maxReadings {
-					maxReadings = count
-					selectedNmi = nmi
-				}
-			}
-			c.logger.Info(fmt.Sprintf("Using NMI %v", selectedNmi))
-		}
-		dailyKWh := 0.0
-		dailyCharge := 0.0
-		monthlyCharge := 0.0
-		readingsThisMonth := 0
-		for _, data := range usage[selectedNmi][nem12.GeneralUsage] {
-			// The plan's tarrif period ranges only have a day and month, so we convert our reading to
-			// the same format so we can see if it's in the tariff period
-			date := time.Date(0, data.StartTime.Month(), data.StartTime.Day(), 0, 0, 0, 0, time.UTC)
-			if !util.InDateRange(start, end, date) {
-				continue
-			}
-			if util.IsMidnight(data.StartTime) {
-				supply, err := strconv.ParseFloat(*tariff.DailySupplyCharges, 64)
-				if err != nil {
-					return cost, fmt.Errorf("couldn't parse daily supply charge: %w", err)
-				}
-				// It's a new day so re-initialise the daily total with the supply charge and zero the kWh
-				dailyCharge = util.WithGST(supply)
-				dailyKWh = 0.0
-			}
-			dailyKWh = dailyKWh + data.EnergyKWh
-			rate, err := getRate(dailyKWh, tariff.SingleRate.Rates)
-			if err != nil {
-				return cost, fmt.Errorf("couldn't get rate: %w", err)
-			}
-			dailyCharge = dailyCharge + (data.EnergyKWh * rate)
-			if util.IsMidnight(data.EndTime) {
-				if data.StartTime.Day() == 1 {
-					// We're at the start of a new month
-					readingsThisMonth = 0
-					monthlyCharge = 0.0
-				}
-				// It's the end of the day, so update the monthly total with today's total
-				monthlyCharge = monthlyCharge + dailyCharge
-				readingsThisMonth = readingsThisMonth + 1
-				if data.EndTime.Day() == 1 {
-					// We're at the end of the month
-					if readingsThisMonth < util.DaysInMonth(data.StartTime) {
-						// If we have less than 2 weeks of readings, we discount the month completely.
-						// There's not enough data to go on. If we have more, we extrapolate the rest.
-						if readingsThisMonth < 14 {
-							continue
-						} else {
-							dailyAvg := monthlyCharge / float64(readingsThisMonth)
-							missingDays := util.DaysInMonth(data.StartTime) - readingsThisMonth
-							monthlyCharge = monthlyCharge + (dailyAvg * float64(missingDays))
-						}
-					}
-					monthlyTotals[int(data.StartTime.Month())-1] = monthlyTotals[int(data.StartTime.Month())-1] + monthlyCharge
-					monthlyReadings[int(data.StartTime.Month())-1] = monthlyReadings[int(data.StartTime.Month())-1] + 1
-				}
+		if daysThisMonth < util.DaysInMonth(month) {
+			// If we have less than 2 weeks of readings, we discount the month completely.
+			// There's not enough data to go on. If we have more, we extrapolate the rest.
+			if daysThisMonth < 14 {
+				c.explain(nmi, MonthStep, "Dropped %v because it only has %v days of data",
+					month.Format("January 2006"), daysThisMonth)
+				return
 			}
+			dailyAvg := monthlyCharge / float64(daysThisMonth)
+			missingDays := util.DaysInMonth(month) - daysThisMonth
+			monthlyCharge = monthlyCharge + (dailyAvg * float64(missingDays))
+			c.explain(nmi, MonthStep, "Extrapolated the %v missing days of %v from the average of its %v days of data",
+				missingDays, month.Format("January 2006"), daysThisMonth)
+		}
+		monthlyTotals[int(month.Month())-1] = monthlyTotals[int(month.Month())-1] + monthlyCharge
+		monthlyReadings[int(month.Month())-1] = monthlyReadings[int(month.Month())-1] + 1
+	}
+	for _, day := range days {
+		// Days can be missing, so we check which month the day is in rather than waiting for the
+		// last day of a month to come around
+		dayMonth := util.StartOfMonth(day.date)
+		if !dayMonth.Equal(month) {
+			finaliseMonth()
+			month = dayMonth
+			monthlyCharge = 0.0
+			daysThisMonth = 0
 		}
+		monthlyCharge = monthlyCharge + day.total()
+		daysThisMonth = daysThisMonth + 1
 	}
+	finaliseMonth()
+
 	validMonthlyReadings := 0
+	hasData := make([]bool, 12)
 	for i, total := range monthlyTotals {
 		if monthlyReadings[i] != 0 {
 			cost.AveragePerMonth[i] = total / float64(monthlyReadings[i])
 			cost.AverageMonthly = cost.AverageMonthly + cost.AveragePerMonth[i]
 			validMonthlyReadings = validMonthlyReadings + 1
+			hasData[i] = true
 		}
 	}
-	cost.AverageMonthly = cost.AverageMonthly / float64(validMonthlyReadings)
+	if validMonthlyReadings != 0 {
+		cost.AverageMonthly = cost.AverageMonthly / float64(validMonthlyReadings)
+	}
+	var filled []filledMonth
+	cost.Annualised, filled = annualise(cost.AveragePerMonth, hasData)
+	for _, f := range filled {
+		cost.ExtrapolatedMonths = append(cost.ExtrapolatedMonths, f.month)
+		how := "interpolating between the nearest months with data"
+		if f.seasonal {
+			how = "the other months in the same season"
+		}
+		c.explain(nmi, AnnualiseStep, "Estimated %v at %.2f a day from %v", f.month, f.dailyCost, how)
+	}
 	return cost, nil
 }
 
-// Gets the correct single rate price based on the plan's rate brackets and how much energy we've
-// currently used.
-// Note: This only looks at the amount including the current reading. This means if the kWh used
-// actually goes between the rate brackets, we won't calculate that properly. We should actually
-// pass in the kWh used before this reading and after this reading into a function to calculate
-// that for a perfectly accurate determination of the charge for this hour.
-func getRate(kWhUsed float64, rates []struct {
-	MeasureUnit *cdsenergy.EnergyPlanContractFullTariffPeriodSingleRateRatesMeasureUnit "json:\"measureUnit,omitempty\""
-	UnitPrice   string                                                                  "json:\"unitPrice\""
-	Volume      *float32                                                                "json:\"volume,omitempty\""
-}) (float64, error) {
-	for _, rate := range rates {
-		if rate.Volume != nil {
-			volume := float64(*rate.Volume)
-			if kWhUsed < volume {
-				price, err := strconv.ParseFloat(rate.UnitPrice, 64)
-				if err != nil {
-					return 0, fmt.Errorf("couldn't parse unit price: %w", err)
-				}
-				return util.WithGST(price), nil
-			}
-		} else {
-			// If volume is nil, that indicates the rate for the "remaining" energy.
-			// If we get to this point, we've used more than the non-nil ranges so we use this value.
-			price, err := strconv.ParseFloat(rate.UnitPrice, 64)
-			if err != nil {
-				return 0, fmt.Errorf("couldn't parse unit price: %w", err)
-			}
-			return util.WithGST(price), nil
+// Gets the NMIs in the usage data in order, so they're always costed and explained in the same order
+func sortedNMIs(usage nem12.UsageData) []nem12.NMI {
+	nmis := make([]nem12.NMI, 0, len(usage))
+	for nmi := range usage {
+		nmis = append(nmis, nmi)
+	}
+	sort.Slice(nmis, func(i, j int) bool { return nmis[i] < nmis[j] })
+	return nmis
+}
+
+// Leaves out readings the quality policy says not to cost
+func (c *Calculator) applyQualityPolicy(nmi nem12.NMI, readings []nem12.HourlyReading) []nem12.HourlyReading {
+	if c.quality != ActualOnly {
+		return readings
+	}
+	actual := actualDaysOnly(readings)
+	left := len(splitDays(readings)) - len(splitDays(actual))
+	if left > 0 {
+		c.explain(nmi, QualityStep, "Left out %v days with estimated or substituted readings", left)
+	}
+	return actual
+}
+
+// Works out the share of actual reads, overall and per month, and the share of imputed readings for
+// the readings being costed. Call this once the quality policy has been applied.
+func (c *Calculator) setDataQuality(cost *Cost, readings []nem12.HourlyReading) {
+	actual := make([]int, 12)
+	total := make([]int, 12)
+	imputed := 0
+	for _, reading := range readings {
+		month := int(reading.StartTime.Month()) - 1
+		total[month] = total[month] + 1
+		if reading.IsActual() {
+			actual[month] = actual[month] + 1
+		}
+		if reading.Imputed {
+			imputed = imputed + 1
+		}
+	}
+	allActual := 0
+	allTotal := 0
+	for i := range total {
+		if total[i] != 0 {
+			cost.ActualSharePerMonth[i] = float64(actual[i]) / float64(total[i])
 		}
+		allActual = allActual + actual[i]
+		allTotal = allTotal + total[i]
+	}
+	if allTotal != 0 {
+		cost.ActualShare = float64(allActual) / float64(allTotal)
+		cost.ImputedShare = float64(imputed) / float64(allTotal)
 	}
-	// This should never happen, unless we've misinterpreted the possible values for the rates array
-	// (which is always possible)
-	return 0, errors.New("couldn't find a rate")
+	cost.readingsPerMonth = total
+	cost.imputedReadings = imputed
 }
 
-func (c *Calculator) calculateTimeOfUse(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) (Cost, error) {
-	panic("unimplemented")
+// Removes every day that contains a reading the meter estimated or substituted. Readings imputed to
+// fill gaps don't count. Whole days are removed because the daily supply charge and rate blocks are
+// worked out a day at a time.
+func actualDaysOnly(readings []nem12.HourlyReading) []nem12.HourlyReading {
+	estimatedDays := make(map[time.Time]bool)
+	for _, reading := range readings {
+		if !reading.IsActual() && !reading.Imputed {
+			estimatedDays[util.StartOfDay(reading.StartTime)] = true
+		}
+	}
+	actual := make([]nem12.HourlyReading, 0, len(readings))
+	for _, reading := range readings {
+		if !estimatedDays[util.StartOfDay(reading.StartTime)] {
+			actual = append(actual, reading)
+		}
+	}
+	return actual
 }
@@ -0,0 +1,188 @@
+package calculator
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+func TestActualOnlyKeepsImputedReadings(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+	readings := usage["NMI1234567"][nem12.GeneralUsage]
+	for i := range readings {
+		switch readings[i].StartTime.Day() {
+		case 2:
+			// Filled in by imputation, so there's no quality method
+			readings[i].QualityMethod = nil
+			readings[i].Imputed = true
+		case 3:
+			if readings[i].StartTime.Hour() == 12 {
+				readings[i].QualityMethod = []string{"E52"}
+			}
+		}
+	}
+	calc := NewCalculator(testLogger(), ActualOnly, 0, nil)
+
+	costs, err := calc.CalculateMonthly(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	// Only the estimated day is left out, and it's extrapolated from the other 30
+	if want := 31 * (110 + 24*11.0); !closeTo(cost.AveragePerMonth[0], want) {
+		t.Errorf("got %v for January, want %v", cost.AveragePerMonth[0], want)
+	}
+	// The shares are of the 30 days that were costed
+	if !closeTo(cost.ActualShare, 29.0/30) || !closeTo(cost.ImputedShare, 1.0/30) {
+		t.Errorf("got an actual share of %v and an imputed share of %v, want 29/30 and 1/30", cost.ActualShare, cost.ImputedShare)
+	}
+}
+
+// Leaves out the readings in the given months
+func withoutMonths(readings []nem12.HourlyReading, months ...time.Month) []nem12.HourlyReading {
+	return slices.DeleteFunc(readings, func(reading nem12.HourlyReading) bool {
+		return slices.Contains(months, reading.StartTime.Month())
+	})
+}
+
+func TestCombineCosts(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The house is missing July and all its readings are actual
+	house := withoutMonths(hourlyUsage(from, to, 1)["NMI1234567"][nem12.GeneralUsage], time.July)
+	// The granny flat is missing February and March. The first 10 days of January were estimated and
+	// the next 5 were imputed.
+	flat := withoutMonths(hourlyUsage(from, to, 1)["NMI1234567"][nem12.GeneralUsage], time.February, time.March)
+	for i := range flat {
+		if flat[i].StartTime.Month() != time.January {
+			continue
+		}
+		switch day := flat[i].StartTime.Day(); {
+		case day <= 10:
+			flat[i].QualityMethod = []string{"E52"}
+		case day <= 15:
+			flat[i].QualityMethod = nil
+			flat[i].Imputed = true
+		}
+	}
+	usage := nem12.UsageData{
+		"NMI1234567": {nem12.GeneralUsage: house},
+		"NMI7654321": {nem12.GeneralUsage: flat},
+	}
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	costs, err := calc.CalculateMonthly(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined := CombineCosts(costs)
+
+	// Each month missing from either NMI had to be filled in
+	want := []time.Month{time.February, time.March, time.July}
+	if !slices.Equal(combined.ExtrapolatedMonths, want) {
+		t.Errorf("got extrapolated months %v, want %v", combined.ExtrapolatedMonths, want)
+	}
+	january := costs["NMI1234567"].AveragePerMonth[0] + costs["NMI7654321"].AveragePerMonth[0]
+	if !closeTo(combined.AveragePerMonth[0], january) {
+		t.Errorf("got %v for January, want %v", combined.AveragePerMonth[0], january)
+	}
+	annualised := costs["NMI1234567"].Annualised + costs["NMI7654321"].Annualised
+	if !closeTo(combined.Annualised, annualised) {
+		t.Errorf("got an annualised cost of %v, want %v", combined.Annualised, annualised)
+	}
+
+	// All 744 of the house's readings in January were actual, and 384 of the flat's
+	if want := 1128.0 / 1488; !closeTo(combined.ActualSharePerMonth[0], want) {
+		t.Errorf("got an actual share of %v for January, want %v", combined.ActualSharePerMonth[0], want)
+	}
+	// Months only one NMI has readings for take its share
+	if combined.ActualSharePerMonth[1] != 1 || combined.ActualSharePerMonth[6] != 1 {
+		t.Errorf("got actual shares of %v for February and %v for July, want 1", combined.ActualSharePerMonth[1], combined.ActualSharePerMonth[6])
+	}
+	// Weighted by the 8016 readings from the house and 7344 from the flat, 360 of which weren't actual
+	if want := 15000.0 / 15360; !closeTo(combined.ActualShare, want) {
+		t.Errorf("got an actual share of %v, want %v", combined.ActualShare, want)
+	}
+	if want := 120.0 / 15360; !closeTo(combined.ImputedShare, want) {
+		t.Errorf("got an imputed share of %v, want %v", combined.ImputedShare, want)
+	}
+}
+
+// Marks the readings for which fn returns true as estimated
+func estimate(usage nem12.UsageData, fn func(reading nem12.HourlyReading) bool) {
+	readings := usage["NMI1234567"][nem12.GeneralUsage]
+	for i := range readings {
+		if fn(readings[i]) {
+			readings[i].QualityMethod = []string{"E52"}
+		}
+	}
+}
+
+func TestActualShare(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), 1)
+	// Every reading on the first 6 days of January, and one reading on the 7th
+	estimate(usage, func(reading nem12.HourlyReading) bool {
+		return reading.StartTime.Before(time.Date(2023, 1, 7, 0, 0, 0, 0, time.UTC)) || reading.StartTime.Equal(time.Date(2023, 1, 7, 12, 0, 0, 0, time.UTC))
+	})
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	costs, err := calc.CalculateMonthly(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	// Estimates are costed like any other reading
+	if want := 31 * (110 + 24*11.0); !closeTo(cost.AveragePerMonth[0], want) {
+		t.Errorf("got %v for January, want %v", cost.AveragePerMonth[0], want)
+	}
+	if want := 1 - 145.0/744; !closeTo(cost.ActualSharePerMonth[0], want) {
+		t.Errorf("got an actual share of %v for January, want %v", cost.ActualSharePerMonth[0], want)
+	}
+	if cost.ActualSharePerMonth[1] != 1 || cost.ActualSharePerMonth[2] != 0 {
+		t.Errorf("got actual shares of %v for February and %v for March, want 1 and 0", cost.ActualSharePerMonth[1], cost.ActualSharePerMonth[2])
+	}
+	if want := 1 - 145.0/1416; !closeTo(cost.ActualShare, want) || cost.ImputedShare != 0 {
+		t.Errorf("got an actual share of %v and an imputed share of %v, want %v and 0", cost.ActualShare, cost.ImputedShare, want)
+	}
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == QualityStep {
+			t.Errorf("explained leaving out readings when all of them were costed: %v", step.Text)
+		}
+	}
+}
+
+func TestActualOnlyLeavesOutWholeDays(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), 1)
+	// A single estimated hour on each of the first 3 days of February
+	estimate(usage, func(reading nem12.HourlyReading) bool {
+		return reading.StartTime.Month() == time.February && reading.StartTime.Day() <= 3 && reading.StartTime.Hour() == 18
+	})
+	calc := NewCalculator(testLogger(), ActualOnly, 0, nil)
+
+	bills, err := calc.CalculateBills(usage, singleRatePlan(t, ""), "P1M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bills["NMI1234567"]) != 2 {
+		t.Fatalf("got %v bills, want 2", len(bills["NMI1234567"]))
+	}
+	// The supply charge of the days left out isn't charged either
+	february := bills["NMI1234567"][1]
+	if february.MissingDays != 3 || !closeTo(february.Total, 25*(110+24*11.0)) {
+		t.Errorf("got %v missing days and a total of %v for February, want 3 and %v", february.MissingDays, february.Total, 25*(110+24*11.0))
+	}
+	if january := bills["NMI1234567"][0]; january.MissingDays != 0 {
+		t.Errorf("got %v missing days in January, want 0", january.MissingDays)
+	}
+	found := false
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == QualityStep && step.Text == "Left out 3 days with estimated or substituted readings" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("didn't explain the days left out:\n%v", calc.Explanation().Text())
+	}
+}
@@ -0,0 +1,141 @@
+package calculator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// Details of a household that change what it pays regardless of plan
+type Household struct {
+	// Concessions and rebates the household is eligible for, in the same form as the accounts
+	// concessions endpoint. Amounts are in the same units as the plans. State energy rebates can be
+	// described as a fixed amount with the frequency they're paid at, e.g. P1Y for an annual rebate.
+	Concessions []cdsenergy.EnergyConcession `json:"concessions"`
+}
+
+// Reads a household profile from a JSON file
+func LoadHousehold(path string) (*Household, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var household Household
+	err = json.Unmarshal(data, &household)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse household profile: %w", err)
+	}
+	return &household, nil
+}
+
+// The charges on a bill that concessions can apply to
+type billCharges struct {
+	supply float64
+	usage  float64
+	// Everything on the bill so far, including discounts
+	invoice float64
+}
+
+// Works out the household's concessions on a bill. A concession reduces the charges it applies to,
+// but never below zero.
+func (c *Calculator) billConcessions(nmi nem12.NMI, start, end time.Time, charges billCharges) ([]LineItem, error) {
+	if c.household == nil {
+		return nil, nil
+	}
+	var items []LineItem
+	for _, concession := range c.household.Concessions {
+		active, err := concessionActive(concession, start)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			continue
+		}
+		base := concessionBase(concession, charges)
+		var amount float64
+		switch concession.Type {
+		case cdsenergy.EnergyConcessionTypeFIXEDAMOUNT:
+			if concession.Amount == nil || concession.DiscountFrequency == nil {
+				return nil, fmt.Errorf("concession %v needs an amount and discount frequency", concession.DisplayName)
+			}
+			perPeriod, err := strconv.ParseFloat(*concession.Amount, 64)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse concession amount: %w", err)
+			}
+			frequency, err := util.ParsePeriod(*concession.DiscountFrequency)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse concession frequency: %w", err)
+			}
+			// Spread the amount over the days of the bill
+			amount = perPeriod * float64(daysBetween(start, end)) / float64(frequency.DaysFrom(start))
+		case cdsenergy.EnergyConcessionTypeFIXEDPERCENTAGE:
+			if concession.Percentage == nil {
+				return nil, fmt.Errorf("concession %v needs a percentage", concession.DisplayName)
+			}
+			percent, err := parsePercentage(*concession.Percentage)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse concession percentage: %w", err)
+			}
+			amount = percent * base
+		default:
+			c.explain(nmi, ConcessionStep, "Not applied: %v is a variable concession, which can't be calculated",
+				concession.DisplayName)
+			continue
+		}
+		amount = min(amount, max(base, 0))
+		c.explain(nmi, ConcessionStep, "Applied %v for %.2f on the bill from %v", concession.DisplayName, amount,
+			start.Format(time.DateOnly))
+		items = append(items, LineItem{Description: "Concession: " + concession.DisplayName, Amount: -amount})
+	}
+	return items, nil
+}
+
+// Checks whether a bill starting on the given day falls within the concession's start and end dates
+func concessionActive(concession cdsenergy.EnergyConcession, start time.Time) (bool, error) {
+	if concession.StartDate != nil {
+		startDate, err := time.Parse(time.DateOnly, *concession.StartDate)
+		if err != nil {
+			return false, fmt.Errorf("couldn't parse concession start date: %w", err)
+		}
+		if start.Before(startDate) {
+			return false, nil
+		}
+	}
+	if concession.EndDate != nil {
+		endDate, err := time.Parse(time.DateOnly, *concession.EndDate)
+		if err != nil {
+			return false, fmt.Errorf("couldn't parse concession end date: %w", err)
+		}
+		if start.After(endDate) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Adds up the charges a concession applies to. If it applies to the whole invoice, that covers
+// everything else. Controlled load isn't costed yet, so it adds nothing.
+func concessionBase(concession cdsenergy.EnergyConcession, charges billCharges) float64 {
+	appliedTo := []cdsenergy.EnergyConcessionAppliedTo{cdsenergy.USAGE}
+	if concession.AppliedTo != nil {
+		appliedTo = *concession.AppliedTo
+	}
+	if slices.Contains(appliedTo, cdsenergy.INVOICE) {
+		return charges.invoice
+	}
+	base := 0.0
+	if slices.Contains(appliedTo, cdsenergy.USAGE) {
+		base = base + charges.usage
+	}
+	if slices.Contains(appliedTo, cdsenergy.SERVICECHARGE) {
+		base = base + charges.supply
+	}
+	return base
+}
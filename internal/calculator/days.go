@@ -0,0 +1,327 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// Returned for plans with time of use pricing, which can't be costed yet
+var ErrTimeOfUseUnsupported = errors.New("time of use pricing isn't supported yet")
+
+// The cost of one day of usage. Every other cost is built up from these.
+type dayCost struct {
+	date time.Time
+	// The daily supply charge, including GST
+	supply float64
+	// The cost of the energy used, including GST
+	usage float64
+	kWh   float64
+	// The GreenPower charge, including GST
+	green float64
+	// The wholesale spot price passed through, including GST
+	wholesale float64
+	// How each reading in the day was costed
+	intervals []IntervalCost
+}
+
+func (d dayCost) total() float64 {
+	return d.supply + d.usage + d.green + d.wholesale
+}
+
+// A tariff period's dates, which only have a month and day so they can apply to any year
+type tariffDates struct {
+	start time.Time
+	end   time.Time
+}
+
+func parseTariffDates(start, end string) (tariffDates, error) {
+	startDate, err := time.Parse("01-02", start)
+	if err != nil {
+		return tariffDates{}, err
+	}
+	endDate, err := time.Parse("01-02", end)
+	if err != nil {
+		return tariffDates{}, err
+	}
+	return tariffDates{start: startDate, end: endDate}, nil
+}
+
+func (t tariffDates) contains(date time.Time) bool {
+	// We convert the date to the same format as the tariff period so we can compare them
+	return util.InDateRange(t.start, t.end, time.Date(0, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// Parses the dates of each of the contract's tariff periods, along with the period each one's rate
+// block volumes are for. Without one, we assume the volumes are daily.
+func parseTariffPeriods(contract *cdsenergy.EnergyPlanContractFull) ([]tariffDates, []util.Period, error) {
+	tariffs := contract.TariffPeriod
+	dates := make([]tariffDates, len(tariffs))
+	blockPeriods := make([]util.Period, len(tariffs))
+	for i, tariff := range tariffs {
+		d, err := parseTariffDates(tariff.StartDate, tariff.EndDate)
+		if err != nil {
+			return nil, nil, err
+		}
+		dates[i] = d
+		blockPeriods[i] = util.Period{Days: 1}
+		if tariff.SingleRate != nil && tariff.SingleRate.Period != nil {
+			blockPeriods[i], err = util.ParsePeriod(*tariff.SingleRate.Period)
+			if err != nil {
+				return nil, nil, fmt.Errorf("couldn't parse rate period: %w", err)
+			}
+		}
+	}
+	return dates, blockPeriods, nil
+}
+
+// The dates a bill covers, from the first day up to but not including the end
+type billPeriod struct {
+	start time.Time
+	end   time.Time
+}
+
+// Costs the NMI's readings a day at a time. The readings must be sorted. Days that aren't covered
+// by any of the plan's tariff periods are left out. If the readings make up a bill, usage builds up
+// towards rate block thresholds over the whole bill rather than starting again each day.
+func (c *Calculator) costDays(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail, bill *billPeriod) ([]dayCost, error) {
+	green, err := c.greenPowerCharge(nmi, plan)
+	if err != nil {
+		return nil, err
+	}
+	var days []dayCost
+	switch plan.ElectricityContract.PricingModel {
+	case cdsenergy.EnergyPlanContractFullPricingModelSINGLERATE, cdsenergy.EnergyPlanContractFullPricingModelSINGLERATECONTLOAD:
+		days, err = c.costSingleRateDays(nmi, readings, plan, bill)
+	case cdsenergy.EnergyPlanContractFullPricingModelTIMEOFUSE, cdsenergy.EnergyPlanContractFullPricingModelTIMEOFUSECONTLOAD:
+		days, err = c.costTimeOfUseDays(nmi, readings, plan, bill)
+	default:
+		return nil, fmt.Errorf("unsupported pricing model %v", plan.ElectricityContract.PricingModel)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if green != nil {
+		for i := range days {
+			interval, err := green.forDay(days[i])
+			if err != nil {
+				return nil, err
+			}
+			days[i].green = interval.Cost
+			days[i].intervals = append(days[i].intervals, interval)
+		}
+	}
+	if c.wholesale != nil {
+		days = c.addWholesale(nmi, days, readings)
+	}
+	return days, nil
+}
+
+func (c *Calculator) costSingleRateDays(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail, bill *billPeriod) ([]dayCost, error) {
+	tariffs := plan.ElectricityContract.TariffPeriod
+	dates, blockPeriods, err := parseTariffPeriods(plan.ElectricityContract)
+	if err != nil {
+		return nil, err
+	}
+	// The usage counted towards the rate block thresholds so far
+	blockKWh := 0.0
+
+	// For the explanation, we group consecutive days with the same tariff period (or no tariff
+	// period, which is -1) into runs, and count the days each rate block was reached
+	run := tariffRun{tariff: -2}
+	endRun := func() {
+		if run.days == 0 {
+			return
+		}
+		if run.tariff == -1 {
+			c.explain(nmi, TariffStep, "No tariff period covers %v to %v, so those %v days weren't costed",
+				run.from.Format(time.DateOnly), run.to.Format(time.DateOnly), run.days)
+			return
+		}
+		tariff := tariffs[run.tariff]
+		c.explain(nmi, TariffStep, "Tariff period %v (%v to %v) applied to %v days from %v to %v",
+			tariff.DisplayName, tariff.StartDate, tariff.EndDate, run.days, run.from.Format(time.DateOnly), run.to.Format(time.DateOnly))
+	}
+	supplyDays := make([]int, len(tariffs))
+	supplyCharges := make([]float64, len(tariffs))
+	blockDays := make([]map[int]int, len(tariffs))
+
+	days := make([]dayCost, 0, len(readings)/24+1)
+	for _, dayReadings := range splitDays(readings) {
+		date := util.StartOfDay(dayReadings[0].StartTime)
+		t := -1
+		for i := range dates {
+			if dates[i].contains(date) {
+				t = i
+				break
+			}
+		}
+		if t != run.tariff || !date.Equal(run.to.AddDate(0, 0, 1)) {
+			endRun()
+			run = tariffRun{tariff: t, from: date}
+		}
+		run.to = date
+		run.days = run.days + 1
+		if t == -1 {
+			c.logger.Debug("No tariff period covers day, skipping it", slog.Time("date", date))
+			continue
+		}
+		tariff := tariffs[t]
+		if tariff.SingleRate == nil {
+			return nil, fmt.Errorf("tariff period %v has no single rate", tariff.DisplayName)
+		}
+		day := dayCost{date: date, intervals: make([]IntervalCost, 0, len(dayReadings)+1)}
+		if tariff.DailySupplyCharges != nil {
+			supply, err := strconv.ParseFloat(*tariff.DailySupplyCharges, 64)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse daily supply charge: %w", err)
+			}
+			day.supply = util.WithGST(supply)
+			supplyDays[t] = supplyDays[t] + 1
+			supplyCharges[t] = day.supply
+		}
+		highestBlock := 0
+		day.intervals = append(day.intervals, IntervalCost{
+			StartTime:    date,
+			EndTime:      date.AddDate(0, 0, 1),
+			Rate:         day.supply,
+			Cost:         day.supply,
+			Component:    SupplyComponent,
+			TariffPeriod: tariff.DisplayName,
+			unit:         "days",
+		})
+		if bill == nil {
+			blockKWh = 0.0
+		}
+		scale := blockScale(blockPeriods[t], date, bill)
+		for _, reading := range dayReadings {
+			day.kWh = day.kWh + reading.EnergyKWh
+			blockKWh = blockKWh + reading.EnergyKWh
+			rate, block, err := getRate(blockKWh/scale, tariff.SingleRate.Rates)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get rate: %w", err)
+			}
+			day.usage = day.usage + (reading.EnergyKWh * rate)
+			highestBlock = max(highestBlock, block)
+			day.intervals = append(day.intervals, IntervalCost{
+				StartTime:    reading.StartTime,
+				EndTime:      reading.EndTime,
+				EnergyKWh:    reading.EnergyKWh,
+				Rate:         rate,
+				Cost:         reading.EnergyKWh * rate,
+				Component:    fmt.Sprintf("block %v", block+1),
+				TariffPeriod: tariff.DisplayName,
+				unit:         "kWh",
+			})
+		}
+		if blockDays[t] == nil {
+			blockDays[t] = make(map[int]int)
+		}
+		for block := 0; block <= highestBlock; block++ {
+			blockDays[t][block] = blockDays[t][block] + 1
+		}
+		days = append(days, day)
+	}
+	endRun()
+
+	for t, tariff := range tariffs {
+		if supplyDays[t] > 0 {
+			c.explain(nmi, SupplyStep, "Charged %v's daily supply charge of %v plus GST (%.4f) on %v days",
+				tariff.DisplayName, *tariff.DailySupplyCharges, supplyCharges[t], supplyDays[t])
+		}
+		if tariff.SingleRate == nil {
+			continue
+		}
+		for block, rate := range tariff.SingleRate.Rates {
+			if blockDays[t][block] == 0 {
+				continue
+			}
+			c.explain(nmi, BlockStep, "%v block %v (%v at %v plus GST) was used on %v days",
+				tariff.DisplayName, block+1, describeBlock(block, tariff.SingleRate.Rates, blockPeriods[t]), rate.UnitPrice, blockDays[t][block])
+		}
+	}
+	return days, nil
+}
+
+// Consecutive days costed with the same tariff period
+type tariffRun struct {
+	// Index of the tariff period, or -1 if no tariff period covered the days
+	tariff int
+	from   time.Time
+	to     time.Time
+	days   int
+}
+
+// Works out how much longer the span usage builds up over (a day, or the whole bill) is than the
+// period the rate block volumes are for. Dividing usage by this lets us compare it with the volumes
+// as they are, which is the same as scaling the volumes to the span. For example, with quarterly
+// volumes, a day's usage is compared as if it were a quarter's worth.
+func blockScale(blockPeriod util.Period, date time.Time, bill *billPeriod) float64 {
+	start := date
+	spanDays := 1
+	if bill != nil {
+		start = bill.start
+		spanDays = daysBetween(bill.start, bill.end)
+	}
+	return float64(spanDays) / float64(blockPeriod.DaysFrom(start))
+}
+
+// Splits sorted readings into runs that each start on the same day
+func splitDays(readings []nem12.HourlyReading) [][]nem12.HourlyReading {
+	var days [][]nem12.HourlyReading
+	start := 0
+	for i := 1; i <= len(readings); i++ {
+		if i == len(readings) || !util.StartOfDay(readings[i].StartTime).Equal(util.StartOfDay(readings[start].StartTime)) {
+			days = append(days, readings[start:i])
+			start = i
+		}
+	}
+	return days
+}
+
+// Gets the correct single rate price based on the plan's rate brackets and how much energy we've
+// currently used, along with the index of the bracket it came from.
+// Note: This only looks at the amount including the current reading. This means if the kWh used
+// actually goes between the rate brackets, we won't calculate that properly. We should actually
+// pass in the kWh used before this reading and after this reading into a function to calculate
+// that for a perfectly accurate determination of the charge for this hour.
+func getRate(kWhUsed float64, rates []struct {
+	MeasureUnit *cdsenergy.EnergyPlanContractFullTariffPeriodSingleRateRatesMeasureUnit "json:\"measureUnit,omitempty\""
+	UnitPrice   string                                                                  "json:\"unitPrice\""
+	Volume      *float32                                                                "json:\"volume,omitempty\""
+}) (float64, int, error) {
+	for i, rate := range rates {
+		if rate.Volume != nil {
+			volume := float64(*rate.Volume)
+			if kWhUsed < volume {
+				price, err := strconv.ParseFloat(rate.UnitPrice, 64)
+				if err != nil {
+					return 0, 0, fmt.Errorf("couldn't parse unit price: %w", err)
+				}
+				return util.WithGST(price), i, nil
+			}
+		} else {
+			// If volume is nil, that indicates the rate for the "remaining" energy.
+			// If we get to this point, we've used more than the non-nil ranges so we use this value.
+			price, err := strconv.ParseFloat(rate.UnitPrice, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("couldn't parse unit price: %w", err)
+			}
+			return util.WithGST(price), i, nil
+		}
+	}
+	// This should never happen, unless we've misinterpreted the possible values for the rates array
+	// (which is always possible)
+	return 0, 0, errors.New("couldn't find a rate")
+}
+
+func (c *Calculator) costTimeOfUseDays(nmi nem12.NMI, readings []nem12.HourlyReading, plan *cdsenergy.EnergyPlanDetail, bill *billPeriod) ([]dayCost, error) {
+	// Comparisons cost many plans, so this fails rather than panicking to let them skip the plan
+	return nil, ErrTimeOfUseUnsupported
+}
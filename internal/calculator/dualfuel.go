@@ -0,0 +1,229 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/gas"
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+// The cost of electricity and gas usage, either on a single dual fuel plan or on a separate plan for
+// each fuel. Costs cover the period of the usage data, with each plan's discounts applied.
+type FuelCost struct {
+	ElectricityPlan *cdsenergy.EnergyPlanDetail
+	// The same as ElectricityPlan for a dual fuel plan
+	GasPlan     *cdsenergy.EnergyPlanDetail
+	Electricity float64
+	Gas         float64
+	Total       float64
+}
+
+// Checks whether the cost is for a single dual fuel plan
+func (f FuelCost) DualFuel() bool {
+	return f.ElectricityPlan == f.GasPlan
+}
+
+// How dual fuel plans compare with the best separate electricity and gas plans
+type DualFuelComparison struct {
+	// Every dual fuel plan that could be costed, cheapest first
+	DualFuel []FuelCost
+	// The cheapest electricity plan and cheapest gas plan together, which can be from different
+	// retailers. Nil if either fuel had no plans that could be costed.
+	BestSeparate *FuelCost
+	// How much less the cheapest dual fuel plan costs than BestSeparate. Negative if the separate
+	// plans are cheaper.
+	Saving float64
+	// Plans that couldn't be costed, keyed by plan ID, along with why
+	Skipped map[string]error
+}
+
+// Costs each dual fuel plan as a whole and compares them with the cheapest combination of a
+// separate electricity plan and gas plan. Electricity is costed as simulated bills for all the
+// NMIs in the usage data, and gas as a bill for each read, so bundle discounts in either of a dual
+// fuel plan's contracts are applied. Plans that aren't sold in the household's postcode, or that
+// can't be costed (such as time of use plans), are skipped. The explanation only covers the
+// comparison, not how each plan was costed.
+func (c *Calculator) CompareDualFuel(usage nem12.UsageData, reads []gas.Read, postcode string, dualPlans, electricityPlans, gasPlans []*cdsenergy.EnergyPlanDetail) (DualFuelComparison, error) {
+	comparison := DualFuelComparison{Skipped: make(map[string]error)}
+	explanation := &Explanation{}
+	available := func(plan *cdsenergy.EnergyPlanDetail) bool {
+		ok, err := availableIn(plan, postcode)
+		if err != nil {
+			comparison.Skipped[plan.PlanId] = err
+			return false
+		}
+		if !ok {
+			comparison.Skipped[plan.PlanId] = fmt.Errorf("plan isn't available in postcode %v", postcode)
+		}
+		return ok
+	}
+
+	for _, plan := range dualPlans {
+		if !available(plan) {
+			continue
+		}
+		if plan.ElectricityContract == nil || plan.GasContract == nil {
+			comparison.Skipped[plan.PlanId] = errors.New("dual fuel plan is missing a contract")
+			continue
+		}
+		electricity, err := c.electricityTotal(usage, plan)
+		if err != nil {
+			comparison.Skipped[plan.PlanId] = err
+			continue
+		}
+		gasCost, err := c.CalculateGas(reads, plan)
+		if err != nil {
+			comparison.Skipped[plan.PlanId] = err
+			continue
+		}
+		comparison.DualFuel = append(comparison.DualFuel, FuelCost{
+			ElectricityPlan: plan,
+			GasPlan:         plan,
+			Electricity:     electricity,
+			Gas:             gasCost.Total,
+			Total:           electricity + gasCost.Total,
+		})
+	}
+	sort.SliceStable(comparison.DualFuel, func(i, j int) bool {
+		return comparison.DualFuel[i].Total < comparison.DualFuel[j].Total
+	})
+
+	// Costs just add up across fuels, so the best combination is the cheapest plan for each
+	var bestElectricity, bestGas *cdsenergy.EnergyPlanDetail
+	var electricity, gasTotal float64
+	for _, plan := range electricityPlans {
+		if !available(plan) {
+			continue
+		}
+		cost, err := c.electricityTotal(usage, plan)
+		if err != nil {
+			comparison.Skipped[plan.PlanId] = err
+			continue
+		}
+		if bestElectricity == nil || cost < electricity {
+			bestElectricity = plan
+			electricity = cost
+		}
+	}
+	for _, plan := range gasPlans {
+		if !available(plan) {
+			continue
+		}
+		cost, err := c.CalculateGas(reads, plan)
+		if err != nil {
+			comparison.Skipped[plan.PlanId] = err
+			continue
+		}
+		if bestGas == nil || cost.Total < gasTotal {
+			bestGas = plan
+			gasTotal = cost.Total
+		}
+	}
+	if bestElectricity != nil && bestGas != nil {
+		comparison.BestSeparate = &FuelCost{
+			ElectricityPlan: bestElectricity,
+			GasPlan:         bestGas,
+			Electricity:     electricity,
+			Gas:             gasTotal,
+			Total:           electricity + gasTotal,
+		}
+	}
+
+	c.explanation = explanation
+	for _, id := range sortedPlanIDs(comparison.Skipped) {
+		c.explain("", ComparisonStep, "Skipped plan %v: %v", id, comparison.Skipped[id])
+	}
+	if len(comparison.DualFuel) == 0 && comparison.BestSeparate == nil {
+		return comparison, errors.New("no plans could be costed for both electricity and gas")
+	}
+	if len(comparison.DualFuel) == 0 {
+		c.explain("", ComparisonStep, "No dual fuel plans could be costed to compare with the separate plans")
+		return comparison, nil
+	}
+	if comparison.BestSeparate == nil {
+		c.explain("", ComparisonStep, "No separate electricity and gas plans could be costed to compare with")
+		return comparison, nil
+	}
+	best := comparison.DualFuel[0]
+	comparison.Saving = comparison.BestSeparate.Total - best.Total
+	c.explain("", ComparisonStep, "Cheapest dual fuel plan is %v at %.2f, against %.2f for %v electricity and %v gas",
+		best.ElectricityPlan.PlanId, best.Total, comparison.BestSeparate.Total,
+		comparison.BestSeparate.ElectricityPlan.PlanId, comparison.BestSeparate.GasPlan.PlanId)
+	return comparison, nil
+}
+
+// Adds up the simulated bills for every NMI on the plan
+func (c *Calculator) electricityTotal(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) (float64, error) {
+	if plan.ElectricityContract == nil {
+		return 0, errors.New("plan has no electricity contract")
+	}
+	bills, err := c.CalculateBills(usage, plan, "")
+	if err != nil {
+		return 0, fmt.Errorf("couldn't cost electricity: %w", err)
+	}
+	total := 0.0
+	for _, nmiBills := range bills {
+		for _, bill := range nmiBills {
+			total = total + bill.Total
+		}
+	}
+	return total, nil
+}
+
+// Checks whether the plan is sold in the postcode, going by its geography. A plan without a
+// geography is assumed to be available everywhere.
+func availableIn(plan *cdsenergy.EnergyPlanDetail, postcode string) (bool, error) {
+	if plan.Geography == nil {
+		return true, nil
+	}
+	code, err := strconv.Atoi(postcode)
+	if err != nil {
+		return false, fmt.Errorf("couldn't parse postcode %q: %w", postcode, err)
+	}
+	if plan.Geography.ExcludedPostcodes != nil {
+		excluded, err := postcodeIn(code, *plan.Geography.ExcludedPostcodes)
+		if err != nil || excluded {
+			return false, err
+		}
+	}
+	if plan.Geography.IncludedPostcodes == nil {
+		return true, nil
+	}
+	return postcodeIn(code, *plan.Geography.IncludedPostcodes)
+}
+
+// Checks whether the postcode is in a list of postcodes and postcode ranges such as 3000-3999
+func postcodeIn(postcode int, postcodes []string) (bool, error) {
+	for _, entry := range postcodes {
+		from, to, isRange := strings.Cut(entry, "-")
+		low, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return false, fmt.Errorf("couldn't parse plan postcode %q: %w", entry, err)
+		}
+		high := low
+		if isRange {
+			high, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil {
+				return false, fmt.Errorf("couldn't parse plan postcode %q: %w", entry, err)
+			}
+		}
+		if postcode >= low && postcode <= high {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sortedPlanIDs(plans map[string]error) []string {
+	ids := make([]string, 0, len(plans))
+	for id := range plans {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
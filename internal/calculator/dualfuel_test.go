@@ -0,0 +1,53 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/gas"
+)
+
+func TestCompareDualFuelSkipsPlansOutsidePostcode(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+	reads := []gas.Read{{Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), MJ: 1000}}
+	victoria := `{"distributors": ["CitiPower"], "includedPostcodes": ["3000-3999"], "excludedPostcodes": ["3050"]}`
+	dual := singleRatePlan(t, "", planOptions{id: "DUAL", fuels: []string{"electricity", "gas"}, supply: "100", geography: victoria})
+	// The cheapest plans for each fuel, but neither is sold in 3050
+	cheapElectricity := singleRatePlan(t, "", planOptions{id: "CHEAP-ELEC", supply: "1", geography: victoria})
+	cheapGas := singleRatePlan(t, "", planOptions{id: "CHEAP-GAS", fuels: []string{"gas"}, supply: "1",
+		geography: `{"distributors": ["Jemena"], "includedPostcodes": ["2000"]}`})
+	electricity := singleRatePlan(t, "", planOptions{id: "ELEC", supply: "50"})
+	gasPlan := singleRatePlan(t, "", planOptions{id: "GAS", fuels: []string{"gas"}, supply: "50", geography: `{"distributors": ["Multinet"]}`})
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	comparison, err := calc.CompareDualFuel(usage, reads, "3050", []*cdsenergy.EnergyPlanDetail{dual},
+		[]*cdsenergy.EnergyPlanDetail{cheapElectricity, electricity}, []*cdsenergy.EnergyPlanDetail{cheapGas, gasPlan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparison.BestSeparate == nil || comparison.BestSeparate.ElectricityPlan != electricity || comparison.BestSeparate.GasPlan != gasPlan {
+		t.Errorf("got best separate plans %+v, want the ones sold in 3050", comparison.BestSeparate)
+	}
+	if len(comparison.DualFuel) != 0 {
+		t.Errorf("costed a dual fuel plan that excludes 3050")
+	}
+	for _, id := range []string{"DUAL", "CHEAP-ELEC", "CHEAP-GAS"} {
+		if comparison.Skipped[id] == nil {
+			t.Errorf("didn't skip %v", id)
+		}
+	}
+
+	// In 3000 the cheap electricity plan is available, but the cheap gas plan still isn't
+	comparison, err = calc.CompareDualFuel(usage, reads, "3000", []*cdsenergy.EnergyPlanDetail{dual},
+		[]*cdsenergy.EnergyPlanDetail{cheapElectricity, electricity}, []*cdsenergy.EnergyPlanDetail{cheapGas, gasPlan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparison.BestSeparate.ElectricityPlan != cheapElectricity || comparison.BestSeparate.GasPlan != gasPlan {
+		t.Errorf("got best separate plans %v and %v", comparison.BestSeparate.ElectricityPlan.PlanId, comparison.BestSeparate.GasPlan.PlanId)
+	}
+	if len(comparison.DualFuel) != 1 {
+		t.Errorf("didn't cost the dual fuel plan sold in 3000")
+	}
+}
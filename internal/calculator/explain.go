@@ -0,0 +1,171 @@
+package calculator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// What part of the costing a step explains
+type StepKind string
+
+const (
+	// Readings left out because of the quality policy
+	QualityStep StepKind = "quality"
+	// Which tariff period was applied to which days, and days no tariff period covered
+	TariffStep StepKind = "tariff"
+	// The daily supply charge and how many days it was charged for
+	SupplyStep StepKind = "supply"
+	// Rate blocks and how often daily usage went into them
+	BlockStep StepKind = "block"
+	// GreenPower charges for the chosen percentage
+	GreenPowerStep StepKind = "greenpower"
+	// Plan discounts and whether they were applied
+	DiscountStep StepKind = "discount"
+	// Wholesale spot prices passed through, and days without them
+	WholesaleStep StepKind = "wholesale"
+	// Household concessions and rebates and whether they were applied
+	ConcessionStep StepKind = "concession"
+	// Months that were extrapolated from partial data or dropped for not having enough
+	MonthStep StepKind = "month"
+	// Months that were filled in to estimate the cost of a full year
+	AnnualiseStep StepKind = "annualise"
+	// Fixed terms, benefit periods and exit fees
+	TermStep StepKind = "term"
+	// How bills were laid out and anything unusual about them
+	BillStep StepKind = "bill"
+	// Plans compared against each other, and any that couldn't be costed
+	ComparisonStep StepKind = "comparison"
+)
+
+// A single decision made while costing a plan
+type Step struct {
+	NMI  nem12.NMI `json:"nmi,omitempty"`
+	Kind StepKind  `json:"kind"`
+	Text string    `json:"text"`
+}
+
+// An audit trail of how a plan was costed, in the order the decisions were made
+type Explanation struct {
+	Steps []Step `json:"steps"`
+	// The steps added with explainOnce
+	once map[Step]bool
+}
+
+// Renders the explanation as readable text, one step per line
+func (e *Explanation) Text() string {
+	var text strings.Builder
+	for _, step := range e.Steps {
+		if step.NMI != "" {
+			text.WriteString(fmt.Sprintf("[%v] ", step.NMI))
+		}
+		text.WriteString(fmt.Sprintf("%v: %v\n", step.Kind, step.Text))
+	}
+	return text.String()
+}
+
+// Renders the explanation as indented JSON
+func (e *Explanation) JSON() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Adds a step to the explanation of the current calculation
+func (c *Calculator) explain(nmi nem12.NMI, kind StepKind, format string, args ...any) {
+	c.explanation.Steps = append(c.explanation.Steps, Step{
+		NMI:  nmi,
+		Kind: kind,
+		Text: fmt.Sprintf(format, args...),
+	})
+}
+
+// Adds a step to the explanation of the current calculation, unless it's already been added. Use
+// this for steps explained while costing days, which happens once for every bill.
+func (c *Calculator) explainOnce(nmi nem12.NMI, kind StepKind, format string, args ...any) {
+	step := Step{NMI: nmi, Kind: kind, Text: fmt.Sprintf(format, args...)}
+	if c.explanation.once[step] {
+		return
+	}
+	if c.explanation.once == nil {
+		c.explanation.once = make(map[Step]bool)
+	}
+	c.explanation.once[step] = true
+	c.explanation.Steps = append(c.explanation.Steps, step)
+}
+
+// Explains which of the plan's discounts are applied. None of them are yet, but a user comparing
+// our cost with a retailer's needs to know that.
+func (c *Calculator) explainDiscounts(plan *cdsenergy.EnergyPlanDetail) {
+	discounts := plan.ElectricityContract.Discounts
+	if discounts == nil {
+		return
+	}
+	for _, discount := range *discounts {
+		c.explain("", DiscountStep, "Not applied: %v (%v)", discount.DisplayName, discount.MethodUType)
+	}
+}
+
+// Explains that the household's concessions aren't applied. They're worked out on each simulated
+// bill, so they only come off CalculateBills and CalculateHorizon.
+func (c *Calculator) explainConcessions() {
+	if c.household == nil {
+		return
+	}
+	for _, concession := range c.household.Concessions {
+		c.explain("", ConcessionStep, "Not applied: %v, as concessions are only worked out on simulated bills",
+			concession.DisplayName)
+	}
+}
+
+// Describes the range of usage a rate block covers. Block volumes are the total usage over the
+// block period, e.g. a day or a quarter, up to which the block applies.
+func describeBlock(block int, rates []struct {
+	MeasureUnit *cdsenergy.EnergyPlanContractFullTariffPeriodSingleRateRatesMeasureUnit "json:\"measureUnit,omitempty\""
+	UnitPrice   string                                                                  "json:\"unitPrice\""
+	Volume      *float32                                                                "json:\"volume,omitempty\""
+}, period util.Period) string {
+	rate := rates[block]
+	per := describePeriod(period)
+	switch {
+	case len(rates) == 1, block == 0 && rate.Volume == nil:
+		return "all usage"
+	case block == 0:
+		return fmt.Sprintf("usage up to %v kWh %v", *rate.Volume, per)
+	case rate.Volume == nil:
+		return fmt.Sprintf("usage over %v kWh %v", *rates[block-1].Volume, per)
+	default:
+		return fmt.Sprintf("usage from %v to %v kWh %v", *rates[block-1].Volume, *rate.Volume, per)
+	}
+}
+
+// Describes how often a period comes around, e.g. "a quarter" or "every 2 months"
+func describePeriod(period util.Period) string {
+	switch period {
+	case util.Period{Days: 1}:
+		return "a day"
+	case util.Period{Days: 7}:
+		return "a week"
+	case util.Period{Months: 1}:
+		return "a month"
+	case util.Period{Months: 3}:
+		return "a quarter"
+	case util.Period{Years: 1}:
+		return "a year"
+	}
+	var parts []string
+	for _, part := range []struct {
+		n    int
+		unit string
+	}{{period.Years, "year"}, {period.Months, "month"}, {period.Days, "day"}} {
+		switch {
+		case part.n == 1:
+			parts = append(parts, "1 "+part.unit)
+		case part.n > 1:
+			parts = append(parts, fmt.Sprintf("%v %vs", part.n, part.unit))
+		}
+	}
+	return "every " + strings.Join(parts, " and ")
+}
@@ -0,0 +1,96 @@
+package calculator
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExplanationText(t *testing.T) {
+	explanation := &Explanation{Steps: []Step{
+		{Kind: DiscountStep, Text: "Not applied: 10% off (guaranteedDiscount)"},
+		{NMI: "NMI1234567", Kind: MonthStep, Text: "Dropped March 2023 because it only has 5 days of data"},
+	}}
+	want := "discount: Not applied: 10% off (guaranteedDiscount)\n" +
+		"[NMI1234567] month: Dropped March 2023 because it only has 5 days of data\n"
+	if got := explanation.Text(); got != want {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+	if got := (&Explanation{}).Text(); got != "" {
+		t.Errorf("got %q for an empty explanation", got)
+	}
+}
+
+func TestExplanationJSON(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0.3, nil)
+	plan := singleRatePlan(t, `
+		"discounts": [{
+			"displayName": "10% off",
+			"type": "GUARANTEED",
+			"methodUType": "percentOfBill",
+			"percentOfBill": {"rate": "0.1"}
+		}], `+tieredGreenPower)
+	_, err := calc.CalculateMonthly(usage, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	explanation := calc.Explanation()
+	if explanation.Steps[0].NMI != "" || explanation.Steps[len(explanation.Steps)-1].NMI == "" {
+		t.Fatalf("want steps for the whole plan and for the NMI:\n%v", explanation.Text())
+	}
+
+	encoded, err := explanation.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the steps are part of the explanation, and steps for the whole plan don't have an NMI
+	var fields map[string][]map[string]string
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || len(fields["steps"]) != len(explanation.Steps) {
+		t.Fatalf("got %v", string(encoded))
+	}
+	for i, step := range fields["steps"] {
+		if _, ok := step["nmi"]; ok != (explanation.Steps[i].NMI != "") {
+			t.Errorf("got %v for step %+v", step, explanation.Steps[i])
+		}
+	}
+
+	var decoded Explanation
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(decoded.Steps, explanation.Steps) {
+		t.Errorf("got steps %+v after a round trip, want %+v", decoded.Steps, explanation.Steps)
+	}
+}
+
+func TestExplanationStartsAfreshForEachCalculation(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0.3, nil)
+	plan := singleRatePlan(t, tieredGreenPower)
+
+	_, err := calc.CalculateMonthly(usage, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := calc.Explanation().Text()
+	_, err = calc.CalculateMonthly(usage, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Including the steps that are only explained once
+	if second := calc.Explanation().Text(); second != first {
+		t.Errorf("got:\n%v\nthe second time, want:\n%v", second, first)
+	}
+	for _, want := range []StepKind{GreenPowerStep, MonthStep, AnnualiseStep} {
+		if !slices.ContainsFunc(calc.Explanation().Steps, func(step Step) bool { return step.Kind == want }) {
+			t.Errorf("no %v step in:\n%v", want, first)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/gas"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// The cost of gas usage on a plan
+type GasCost struct {
+	// A bill for each read, with the plan's discounts applied
+	Bills []Bill
+	Total float64
+	// Number of days the reads cover
+	Days int
+	MJ   float64
+	// An estimate of the cost of a full year, scaled from the days the reads cover. Gas usage is
+	// very seasonal, so this is only reliable if the reads cover a whole year.
+	Annualised float64
+}
+
+// Costs gas usage against the plan's gas contract. Each read is costed as a bill, since basic
+// meters are read once a bill. Rate block volumes are scaled to the length of each read, and the
+// usage is assumed to be spread evenly over its days. Household concessions aren't applied, as they
+// don't say which fuel they're for.
+func (c *Calculator) CalculateGas(reads []gas.Read, plan *cdsenergy.EnergyPlanDetail) (GasCost, error) {
+	c.explanation = &Explanation{}
+	contract := plan.GasContract
+	if contract == nil {
+		return GasCost{}, errors.New("plan has no gas contract")
+	}
+	if contract.PricingModel != cdsenergy.EnergyPlanContractFullPricingModelSINGLERATE {
+		return GasCost{}, fmt.Errorf("unsupported gas pricing model %v", contract.PricingModel)
+	}
+	if len(reads) == 0 {
+		return GasCost{}, errors.New("no gas reads to cost")
+	}
+	var cost GasCost
+	for _, read := range reads {
+		bill, err := c.calculateGasBill(contract, read)
+		if err != nil {
+			return GasCost{}, fmt.Errorf("couldn't cost gas read from %v: %w", read.Start.Format(time.DateOnly), err)
+		}
+		cost.Bills = append(cost.Bills, bill)
+		cost.Total = cost.Total + bill.Total
+		cost.Days = cost.Days + read.Days()
+		cost.MJ = cost.MJ + read.MJ
+	}
+	cost.Annualised = cost.Total * 365 / float64(cost.Days)
+	if cost.Days < 365 {
+		c.explain("", AnnualiseStep, "Gas reads only cover %v days, so the annual cost is scaled up from them without "+
+			"allowing for the season", cost.Days)
+	}
+	return cost, nil
+}
+
+// Works out the bill for a single gas read
+func (c *Calculator) calculateGasBill(contract *cdsenergy.EnergyPlanContractFull, read gas.Read) (Bill, error) {
+	bill := Bill{Start: read.Start, End: read.End}
+	tariffs := contract.TariffPeriod
+	dates, blockPeriods, err := parseTariffPeriods(contract)
+	if err != nil {
+		return bill, err
+	}
+	days := read.Days()
+	dailyMJ := read.MJ / float64(days)
+
+	type itemKey struct {
+		tariffPeriod string
+		component    string
+		rate         float64
+	}
+	items := make(map[itemKey]*LineItem)
+	var keys []itemKey
+	addItem := func(key itemKey, description string, unit string, quantity float64, amount float64) {
+		item, ok := items[key]
+		if !ok {
+			item = &LineItem{Description: description, Unit: unit, Rate: key.rate}
+			items[key] = item
+			keys = append(keys, key)
+		}
+		item.Quantity = item.Quantity + quantity
+		item.Amount = item.Amount + amount
+	}
+
+	// Usage builds up towards the rate block thresholds over the whole read
+	blockMJ := 0.0
+	supply := 0.0
+	usage := 0.0
+	for date := read.Start; date.Before(read.End); date = date.AddDate(0, 0, 1) {
+		t := -1
+		for i := range dates {
+			if dates[i].contains(date) {
+				t = i
+				break
+			}
+		}
+		if t == -1 {
+			bill.MissingDays = bill.MissingDays + 1
+			continue
+		}
+		tariff := tariffs[t]
+		if tariff.SingleRate == nil {
+			return bill, fmt.Errorf("tariff period %v has no single rate", tariff.DisplayName)
+		}
+		if tariff.DailySupplyCharges != nil {
+			daily, err := strconv.ParseFloat(*tariff.DailySupplyCharges, 64)
+			if err != nil {
+				return bill, fmt.Errorf("couldn't parse daily supply charge: %w", err)
+			}
+			daily = util.WithGST(daily)
+			supply = supply + daily
+			addItem(itemKey{tariff.DisplayName, SupplyComponent, daily},
+				fmt.Sprintf("Daily supply charge (%v)", tariff.DisplayName), "days", 1, daily)
+		}
+		blockMJ = blockMJ + dailyMJ
+		scale := float64(days) / float64(blockPeriods[t].DaysFrom(read.Start))
+		rate, block, err := getRate(blockMJ/scale, tariff.SingleRate.Rates)
+		if err != nil {
+			return bill, fmt.Errorf("couldn't get rate: %w", err)
+		}
+		usage = usage + dailyMJ*rate
+		component := fmt.Sprintf("block %v", block+1)
+		addItem(itemKey{tariff.DisplayName, component, rate},
+			fmt.Sprintf("Usage %v (%v)", component, tariff.DisplayName), "MJ", dailyMJ, dailyMJ*rate)
+	}
+	if bill.MissingDays > 0 {
+		c.explain("", TariffStep, "No tariff period covers %v days of the gas read from %v, so they weren't costed",
+			bill.MissingDays, read.Start.Format(time.DateOnly))
+	}
+	for _, key := range keys {
+		bill.Items = append(bill.Items, *items[key])
+	}
+
+	discounts, err := c.billDiscounts("", contract, read.Start, supply, usage)
+	if err != nil {
+		return bill, err
+	}
+	bill.Items = append(bill.Items, discounts...)
+	for _, item := range bill.Items {
+		bill.Total = bill.Total + item.Amount
+	}
+	return bill, nil
+}
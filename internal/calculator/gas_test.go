@@ -0,0 +1,70 @@
+package calculator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/gas"
+)
+
+func TestCalculateGas(t *testing.T) {
+	plan := singleRatePlan(t, "", planOptions{fuels: []string{"gas"}})
+	// The first 50.5 MJ a day at 10 and the rest at 20. Usage is spread evenly over each day of a
+	// read, so the volume sits between two days' worth to keep the day that crosses it out of it.
+	err := json.Unmarshal([]byte(`{
+		"displayName": "Usage",
+		"rates": [{"unitPrice": "10", "volume": 50.5}, {"unitPrice": "20"}]
+	}`), plan.GasContract.TariffPeriod[0].SingleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := []gas.Read{
+		// 100 MJ a day for 90 days
+		{Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), MJ: 9000},
+		// 40 MJ a day for 91 days, which stays in the first block
+		{Start: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), MJ: 3640},
+	}
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	cost, err := calc.CalculateGas(reads, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cost.Bills) != 2 || cost.Days != 181 || cost.MJ != 12640 {
+		t.Fatalf("got %v bills over %v days for %v MJ", len(cost.Bills), cost.Days, cost.MJ)
+	}
+
+	// The block volume is scaled up to the 90 days of the read, so the first 45 days are in block 1
+	items := make(map[string]LineItem)
+	for _, item := range cost.Bills[0].Items {
+		items[item.Description] = item
+	}
+	supply := items["Daily supply charge (All year)"]
+	if supply.Quantity != 90 || !closeTo(supply.Amount, 90*110) {
+		t.Errorf("got a supply charge of %v for %v days, want %v for 90", supply.Amount, supply.Quantity, 90*110)
+	}
+	first := items["Usage block 1 (All year)"]
+	second := items["Usage block 2 (All year)"]
+	if !closeTo(first.Quantity, 4500) || !closeTo(second.Quantity, 4500) {
+		t.Errorf("charged %v MJ in block 1 and %v in block 2, want 4500 in each", first.Quantity, second.Quantity)
+	}
+	if !closeTo(first.Rate, 11) || !closeTo(second.Rate, 22) {
+		t.Errorf("got rates of %v and %v, want 11 and 22 including GST", first.Rate, second.Rate)
+	}
+	if want := 90*110 + 4500*11 + 4500*22.0; !closeTo(cost.Bills[0].Total, want) {
+		t.Errorf("got %v for the first read, want %v", cost.Bills[0].Total, want)
+	}
+
+	if want := 91*110 + 3640*11.0; !closeTo(cost.Bills[1].Total, want) {
+		t.Errorf("got %v for the second read, want %v", cost.Bills[1].Total, want)
+	}
+	if !closeTo(cost.Total, cost.Bills[0].Total+cost.Bills[1].Total) || !closeTo(cost.Annualised, cost.Total*365/181) {
+		t.Errorf("got a total of %v and annualised cost of %v", cost.Total, cost.Annualised)
+	}
+
+	_, err = calc.CalculateGas(reads, singleRatePlan(t, ""))
+	if err == nil {
+		t.Error("costed gas against a plan without a gas contract")
+	}
+}
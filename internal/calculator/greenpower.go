@@ -0,0 +1,149 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// The component of an interval cost that's a GreenPower charge
+const GreenPowerComponent = "greenpower"
+
+// Returned when a plan can't provide the share of GreenPower asked for
+var ErrGreenPowerUnavailable = errors.New("plan doesn't offer the chosen GreenPower percentage")
+
+// The GreenPower charge for the chosen percentage, from the tier of the plan's charges that covers it
+type greenPowerCharge struct {
+	name       string
+	chargeType cdsenergy.EnergyPlanContractFullGreenPowerChargesType
+	// For fixed charges, the amount including GST. For per unit charges, the rate per kWh including
+	// GST. For percentage charges, the fraction of the bill or usage charges.
+	rate float64
+}
+
+// Checks whether the plan can provide the given share of GreenPower, from 0 to 1, either
+// intrinsically or through its GreenPower charges. Use this to leave out plans that can't.
+func SupportsGreenPower(plan *cdsenergy.EnergyPlanDetail, percent float64) bool {
+	_, _, err := findGreenPowerCharge(plan, percent)
+	return err == nil
+}
+
+// Works out the GreenPower charge for the calculator's chosen percentage. Returns nil if no charge
+// is needed, i.e. no GreenPower was asked for or the plan already includes enough.
+func (c *Calculator) greenPowerCharge(nmi nem12.NMI, plan *cdsenergy.EnergyPlanDetail) (*greenPowerCharge, error) {
+	if c.greenPower <= 0 {
+		return nil, nil
+	}
+	charge, intrinsic, err := findGreenPowerCharge(plan, c.greenPower)
+	if err != nil {
+		return nil, err
+	}
+	if charge == nil {
+		c.explainOnce(nmi, GreenPowerStep, "Plan includes %.0f%% GreenPower, which covers the %.0f%% asked for",
+			intrinsic*100, c.greenPower*100)
+		return nil, nil
+	}
+	c.explainOnce(nmi, GreenPowerStep, "Buying %.0f%% GreenPower (on top of %.0f%% included) through %v, charged %v at %v",
+		(c.greenPower-intrinsic)*100, intrinsic*100, charge.name, charge.chargeType, charge.rate)
+	return charge, nil
+}
+
+// Finds the tier of the plan's GreenPower charges covering the share of GreenPower that isn't
+// already included in the plan. Returns a nil charge if none is needed, along with the share the
+// plan includes.
+func findGreenPowerCharge(plan *cdsenergy.EnergyPlanDetail, percent float64) (*greenPowerCharge, float64, error) {
+	contract := plan.ElectricityContract
+	intrinsic := 0.0
+	if contract.IntrinsicGreenPower != nil {
+		var err error
+		intrinsic, err = parsePercentage(contract.IntrinsicGreenPower.GreenPercentage)
+		if err != nil {
+			return nil, 0, fmt.Errorf("couldn't parse intrinsic GreenPower: %w", err)
+		}
+	}
+	if intrinsic >= percent {
+		return nil, intrinsic, nil
+	}
+	if contract.GreenPowerCharges == nil {
+		return nil, intrinsic, ErrGreenPowerUnavailable
+	}
+	needed := percent - intrinsic
+	for _, charges := range *contract.GreenPowerCharges {
+		// Tiers are in order of increasing percentage, so the first one that goes high enough is the
+		// one that applies
+		for _, tier := range charges.Tiers {
+			upper, err := parsePercentage(tier.PercentGreen)
+			if err != nil {
+				return nil, 0, fmt.Errorf("couldn't parse GreenPower tier: %w", err)
+			}
+			if upper < needed {
+				continue
+			}
+			charge := &greenPowerCharge{name: charges.DisplayName, chargeType: charges.Type}
+			value := tier.Rate
+			if value == nil {
+				value = tier.Amount
+			}
+			if value == nil {
+				return nil, 0, fmt.Errorf("GreenPower tier of %v has no rate or amount", charges.DisplayName)
+			}
+			charge.rate, err = strconv.ParseFloat(*value, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("couldn't parse GreenPower charge: %w", err)
+			}
+			if charge.chargeType != cdsenergy.PERCENTOFBILL && charge.chargeType != cdsenergy.PERCENTOFUSE {
+				charge.rate = util.WithGST(charge.rate)
+			}
+			return charge, intrinsic, nil
+		}
+	}
+	return nil, intrinsic, ErrGreenPowerUnavailable
+}
+
+// Works out the GreenPower charge for a day. Weekly and monthly charges are spread evenly over the
+// days they cover.
+func (g *greenPowerCharge) forDay(day dayCost) (IntervalCost, error) {
+	interval := IntervalCost{
+		StartTime:    day.date,
+		EndTime:      day.date.AddDate(0, 0, 1),
+		Rate:         g.rate,
+		Component:    GreenPowerComponent,
+		TariffPeriod: g.name,
+	}
+	switch g.chargeType {
+	case cdsenergy.FIXEDPERDAY:
+		interval.Cost = g.rate
+		interval.unit = "days"
+	case cdsenergy.FIXEDPERWEEK:
+		interval.Cost = g.rate / 7
+	case cdsenergy.FIXEDPERMONTH:
+		interval.Cost = g.rate * 12 / 365
+	case cdsenergy.FIXEDPERUNIT:
+		interval.EnergyKWh = day.kWh
+		interval.Cost = g.rate * day.kWh
+		interval.unit = "kWh"
+	case cdsenergy.PERCENTOFUSE:
+		interval.Cost = g.rate * day.usage
+	case cdsenergy.PERCENTOFBILL:
+		interval.Cost = g.rate * (day.supply + day.usage)
+	default:
+		return interval, fmt.Errorf("unsupported GreenPower charge type %v", g.chargeType)
+	}
+	return interval, nil
+}
+
+// Parses a percentage, which the plans give either as a fraction (e.g. 0.5) or out of 100 (e.g. 50)
+func parsePercentage(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if percent > 1 {
+		percent = percent / 100
+	}
+	return percent, nil
+}
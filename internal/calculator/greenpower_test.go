@@ -0,0 +1,153 @@
+package calculator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// GreenPower charged per kWh, at 1 up to 10% GreenPower, 2 up to 50% and 3 up to 100%, all before GST
+const tieredGreenPower = `"greenPowerCharges": [{
+	"displayName": "GreenPower",
+	"scheme": "GREENPOWER",
+	"type": "FIXED_PER_UNIT",
+	"tiers": [
+		{"percentGreen": "0.1", "rate": "1"},
+		{"percentGreen": "50", "rate": "2"},
+		{"percentGreen": "1", "rate": "3"}
+	]
+}]`
+
+func TestFindGreenPowerChargeTiers(t *testing.T) {
+	plan := singleRatePlan(t, tieredGreenPower)
+	for _, tc := range []struct {
+		percent float64
+		want    float64
+	}{
+		{0.05, 1.1},
+		{0.1, 1.1},
+		{0.3, 2.2},
+		{0.5, 2.2},
+		{1, 3.3},
+	} {
+		charge, intrinsic, err := findGreenPowerCharge(plan, tc.percent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intrinsic != 0 || charge == nil || !closeTo(charge.rate, tc.want) {
+			t.Errorf("got %+v for %v%% GreenPower, want a rate of %v", charge, tc.percent*100, tc.want)
+		}
+	}
+}
+
+func TestFindGreenPowerChargeIntrinsic(t *testing.T) {
+	plan := singleRatePlan(t, `"intrinsicGreenPower": {"greenPercentage": "0.2"}, `+tieredGreenPower)
+
+	// The plan already has enough
+	charge, intrinsic, err := findGreenPowerCharge(plan, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge != nil || intrinsic != 0.2 {
+		t.Errorf("got %+v with %v included, want no charge", charge, intrinsic)
+	}
+	if !SupportsGreenPower(plan, 0.2) {
+		t.Error("the plan doesn't support the GreenPower it includes")
+	}
+
+	// Only the 40% on top of what's included is bought, which is in the 50% tier
+	charge, _, err = findGreenPowerCharge(plan, 0.6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge == nil || !closeTo(charge.rate, 2.2) {
+		t.Errorf("got %+v for 60%% GreenPower, want the 50%% tier", charge)
+	}
+
+	// Without any charges, only what's included is available
+	plan = singleRatePlan(t, `"intrinsicGreenPower": {"greenPercentage": "20"}`)
+	if !SupportsGreenPower(plan, 0.1) || SupportsGreenPower(plan, 0.5) {
+		t.Error("got the wrong support for GreenPower from a plan that only includes it")
+	}
+}
+
+func TestGreenPowerUnavailable(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0.5, nil)
+	for name, extra := range map[string]string{
+		"no GreenPower": "",
+		"tiers too low": `"greenPowerCharges": [{
+			"displayName": "GreenPower",
+			"scheme": "GREENPOWER",
+			"type": "FIXED_PER_UNIT",
+			"tiers": [{"percentGreen": "0.25", "rate": "1"}]
+		}]`,
+	} {
+		plan := singleRatePlan(t, extra)
+		if SupportsGreenPower(plan, 0.5) {
+			t.Errorf("%v: supports 50%% GreenPower", name)
+		}
+		_, err := calc.CalculateMonthly(usage, plan)
+		if !errors.Is(err, ErrGreenPowerUnavailable) {
+			t.Errorf("%v: got %v, want ErrGreenPowerUnavailable", name, err)
+		}
+	}
+}
+
+func TestGreenPowerChargeTypes(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 1)
+	supply := 31 * 110.0
+	charges := supply + 31*24*11.0
+	for _, tc := range []struct {
+		chargeType string
+		value      string
+		want       float64
+	}{
+		{"FIXED_PER_DAY", `"amount": "10"`, 31 * 11},
+		{"FIXED_PER_WEEK", `"amount": "70"`, 31 * 11},
+		{"FIXED_PER_MONTH", `"amount": "365"`, 31 * 12 * 1.1},
+		{"FIXED_PER_UNIT", `"rate": "2"`, 31 * 24 * 2.2},
+		// Percentages are of charges that already include GST
+		{"PERCENT_OF_USE", `"rate": "0.1"`, 0.1 * 31 * 24 * 11},
+		{"PERCENT_OF_BILL", `"rate": "0.1"`, 0.1 * charges},
+	} {
+		t.Run(tc.chargeType, func(t *testing.T) {
+			plan := singleRatePlan(t, `"greenPowerCharges": [{
+				"displayName": "GreenPower",
+				"scheme": "GREENPOWER",
+				"type": "`+tc.chargeType+`",
+				"tiers": [{"percentGreen": "1", `+tc.value+`}]
+			}]`)
+			calc := NewCalculator(testLogger(), IncludeEstimates, 1, nil)
+			costs, err := calc.CalculateMonthly(usage, plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := costs["NMI1234567"].AveragePerMonth[0] - charges; !closeTo(got, tc.want) {
+				t.Errorf("got a GreenPower charge of %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGreenPowerExplainedOnce(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0.3, nil)
+
+	bills, err := calc.CalculateBills(usage, singleRatePlan(t, tieredGreenPower), "P1M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bills["NMI1234567"]) != 3 {
+		t.Fatalf("got %v bills, want 3", len(bills["NMI1234567"]))
+	}
+	steps := 0
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == GreenPowerStep {
+			steps = steps + 1
+		}
+	}
+	if steps != 1 {
+		t.Errorf("got %v GreenPower steps for 3 bills, want 1:\n%v", steps, calc.Explanation().Text())
+	}
+}
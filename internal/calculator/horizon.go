@@ -0,0 +1,263 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// The cost of staying on a plan for a number of months and then leaving it
+type HorizonCost struct {
+	// The first day on the plan
+	Start time.Time
+	// The day after the last day on the plan
+	End   time.Time
+	Bills []Bill
+	// The exit fee for leaving a fixed term plan before the end of its term. Zero if the term ends
+	// within the horizon or the plan has no fixed term.
+	ExitFee float64
+	// The total of the bills plus the exit fee
+	Total float64
+	// When the plan's benefits (i.e. its discounts) stop applying. Zero if they last the whole
+	// horizon.
+	BenefitsEnd time.Time
+	// The cost of the first year on the plan, with its benefits
+	FirstYear float64
+	// The cost of a year on the plan once its benefits have ended. This is what the plan costs
+	// in the long run if you don't switch.
+	Ongoing float64
+	// Number of days in the horizon that the usage data had nothing to replay for. They aren't
+	// charged, so the costs will be low if this isn't close to zero.
+	MissingDays int
+}
+
+// Costs each NMI staying on the plan for the given number of months from start and then leaving,
+// keyed by NMI. Only start's date is used, and it's taken as a UTC date like the readings. The usage
+// over the horizon is a replay of the usage data, with each day using the most recent day in the
+// data with the same month and day. Bills are issued at the plan's first bill frequency. Benefits
+// expire after the plan's benefit period or fixed term, whichever is first, discounts stop after
+// their end date, and an exit fee is charged if the horizon ends before a fixed term does.
+func (c *Calculator) CalculateHorizon(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail, start time.Time, months int) (map[nem12.NMI]HorizonCost, error) {
+	c.explanation = &Explanation{}
+	if months < 1 {
+		return nil, fmt.Errorf("horizon must be at least a month but was %v", months)
+	}
+	contract := plan.ElectricityContract
+	if len(contract.BillFrequency) == 0 {
+		return nil, errors.New("plan has no bill frequency")
+	}
+	every, err := util.ParsePeriod(contract.BillFrequency[0])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse bill frequency: %w", err)
+	}
+	// Readings are in UTC like every NEM12 time, so the horizon has to be too or each replayed day
+	// would straddle two of them
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, months, 0)
+	yearEnd := start.AddDate(1, 0, 0)
+
+	termYears := fixedTermYears(contract.TermType)
+	var termEnd time.Time
+	if termYears > 0 {
+		termEnd = start.AddDate(termYears, 0, 0)
+	}
+	benefitsEnd, err := c.benefitsEnd(contract, start, termEnd)
+	if err != nil {
+		return nil, err
+	}
+	exitFee := 0.0
+	if !termEnd.IsZero() && end.Before(termEnd) {
+		exitFee, err = exitFeeAmount(contract)
+		if err != nil {
+			return nil, err
+		}
+		c.explain("", TermStep, "Leaving on %v is before the fixed term ends on %v, so the exit fee of %.2f applies",
+			end.Format(time.DateOnly), termEnd.Format(time.DateOnly), exitFee)
+	}
+	if contract.OnExpiryDescription != nil && (!benefitsEnd.IsZero() || !termEnd.IsZero()) {
+		c.explain("", TermStep, "On expiry: %v", *contract.OnExpiryDescription)
+	}
+
+	costs := make(map[nem12.NMI]HorizonCost, len(usage))
+	for _, nmi := range sortedNMIs(usage) {
+		readings := c.applyQualityPolicy(nmi, usage[nmi][nem12.GeneralUsage])
+		if len(readings) == 0 {
+			continue
+		}
+		// Replay enough usage for both the horizon and the first year
+		replayed, missingDays := replayReadings(readings, start, maxTime(end, yearEnd))
+		cost := HorizonCost{Start: start, End: end, ExitFee: exitFee, BenefitsEnd: benefitsEnd}
+		for _, day := range missingDays {
+			if day.Before(end) {
+				cost.MissingDays = cost.MissingDays + 1
+			}
+		}
+		if cost.MissingDays > 0 {
+			c.explain(nmi, QualityStep, "No usage data to replay for %v days of the horizon", cost.MissingDays)
+		}
+
+		cost.Bills, err = c.simulateBills(nmi, replayed, plan, every, start, end, benefitsEnd)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		for _, bill := range cost.Bills {
+			cost.Total = cost.Total + bill.Total
+		}
+		cost.Total = cost.Total + exitFee
+
+		firstYear, err := c.simulateBills(nmi, replayed, plan, every, start, yearEnd, benefitsEnd)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		ongoing, err := c.simulateBills(nmi, replayed, plan, every, start, yearEnd, start)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		for i := range firstYear {
+			cost.FirstYear = cost.FirstYear + firstYear[i].Total
+			cost.Ongoing = cost.Ongoing + ongoing[i].Total
+		}
+		costs[nmi] = cost
+	}
+	if len(costs) == 0 {
+		return nil, errors.New("no general usage readings to cost")
+	}
+	return costs, nil
+}
+
+// Works out when the plan's benefits end, which is at the end of the benefit period or the fixed
+// term, whichever comes first. Returns zero if they never end.
+func (c *Calculator) benefitsEnd(contract *cdsenergy.EnergyPlanContractFull, start, termEnd time.Time) (time.Time, error) {
+	end := termEnd
+	if !termEnd.IsZero() {
+		c.explain("", TermStep, "Fixed term of %v, so benefits last until %v at the latest", *contract.TermType, termEnd.Format(time.DateOnly))
+	}
+	if contract.BenefitPeriod == nil {
+		return end, nil
+	}
+	period, ok := parseBenefitPeriod(*contract.BenefitPeriod)
+	if !ok {
+		c.explain("", TermStep, "Couldn't work out how long the benefit period %q is, so benefits are assumed to last",
+			*contract.BenefitPeriod)
+		return end, nil
+	}
+	periodEnd := period.AddTo(start)
+	if !end.IsZero() && !periodEnd.Before(end) {
+		c.explain("", TermStep, "Benefit period of %v lasts the whole fixed term", *contract.BenefitPeriod)
+		return end, nil
+	}
+	c.explain("", TermStep, "Benefit period of %v, so benefits last until %v", *contract.BenefitPeriod, periodEnd.Format(time.DateOnly))
+	return periodEnd, nil
+}
+
+// Benefit periods are free text, but usually say something like "12 months" or "1 year"
+var benefitPeriodPattern = regexp.MustCompile(`(?i)(\d+)\s*(day|week|month|year)s?`)
+
+func parseBenefitPeriod(description string) (util.Period, bool) {
+	if period, err := util.ParsePeriod(strings.TrimSpace(description)); err == nil {
+		return period, true
+	}
+	match := benefitPeriodPattern.FindStringSubmatch(description)
+	if match == nil {
+		return util.Period{}, false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return util.Period{}, false
+	}
+	switch strings.ToLower(match[2]) {
+	case "day":
+		return util.Period{Days: n}, true
+	case "week":
+		return util.Period{Days: n * 7}, true
+	case "month":
+		return util.Period{Months: n}, true
+	default:
+		return util.Period{Years: n}, true
+	}
+}
+
+// Gets the length of a fixed term in years, or zero if the plan doesn't have one
+func fixedTermYears(termType *cdsenergy.EnergyPlanContractFullTermType) int {
+	if termType == nil {
+		return 0
+	}
+	switch *termType {
+	case cdsenergy.EnergyPlanContractFullTermTypeN1YEAR:
+		return 1
+	case cdsenergy.EnergyPlanContractFullTermTypeN2YEAR:
+		return 2
+	case cdsenergy.EnergyPlanContractFullTermTypeN3YEAR:
+		return 3
+	case cdsenergy.EnergyPlanContractFullTermTypeN4YEAR:
+		return 4
+	case cdsenergy.EnergyPlanContractFullTermTypeN5YEAR:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// Adds up the plan's exit fees, including GST
+func exitFeeAmount(contract *cdsenergy.EnergyPlanContractFull) (float64, error) {
+	if contract.Fees == nil {
+		return 0, nil
+	}
+	total := 0.0
+	for _, fee := range *contract.Fees {
+		if fee.Type != cdsenergy.EnergyPlanContractFullFeesTypeEXIT || fee.Amount == nil {
+			continue
+		}
+		amount, err := strconv.ParseFloat(*fee.Amount, 64)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't parse exit fee: %w", err)
+		}
+		total = total + util.WithGST(amount)
+	}
+	return total, nil
+}
+
+// Builds readings for every day from the from day up to the to day by copying the most recent day
+// in the sorted readings with the same month and day. Returns the readings and the days nothing
+// could be found for.
+func replayReadings(readings []nem12.HourlyReading, from, to time.Time) ([]nem12.HourlyReading, []time.Time) {
+	type monthDay struct {
+		month time.Month
+		day   int
+	}
+	// Later days overwrite earlier ones, so we're left with the most recent
+	latest := make(map[monthDay][]nem12.HourlyReading)
+	for _, day := range splitDays(readings) {
+		latest[monthDay{day[0].StartTime.Month(), day[0].StartTime.Day()}] = day
+	}
+	var replayed []nem12.HourlyReading
+	var missing []time.Time
+	for date := from; date.Before(to); date = date.AddDate(0, 0, 1) {
+		source, ok := latest[monthDay{date.Month(), date.Day()}]
+		if !ok {
+			missing = append(missing, date)
+			continue
+		}
+		offset := date.Sub(util.StartOfDay(source[0].StartTime))
+		for _, reading := range source {
+			reading.StartTime = reading.StartTime.Add(offset)
+			reading.EndTime = reading.EndTime.Add(offset)
+			replayed = append(replayed, reading)
+		}
+	}
+	return replayed, missing
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
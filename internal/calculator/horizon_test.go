@@ -0,0 +1,176 @@
+package calculator
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// What a plan built by singleRatePlan is for, when it isn't an electricity plan sold everywhere
+type planOptions struct {
+	id string
+	// The plan's contracts, e.g. "electricity" and "gas". Electricity if empty.
+	fuels []string
+	// The daily supply charge before GST. 100 if empty.
+	supply string
+	// The plan's geography as JSON, or empty for none
+	geography string
+}
+
+// Builds a single rate plan billed monthly, with a supply charge of 100 a day and a rate of 10 per
+// unit all year, both before GST. extra is added to each of the plan's contracts, e.g.
+// `"termType": "1_YEAR"`.
+func singleRatePlan(t *testing.T, extra string, options ...planOptions) *cdsenergy.EnergyPlanDetail {
+	t.Helper()
+	var opts planOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.fuels) == 0 {
+		opts.fuels = []string{"electricity"}
+	}
+	if opts.supply == "" {
+		opts.supply = "100"
+	}
+	contract := `{
+		"pricingModel": "SINGLE_RATE",
+		"billFrequency": ["P1M"],
+		"tariffPeriod": [{
+			"displayName": "All year",
+			"startDate": "01-01",
+			"endDate": "12-31",
+			"dailySupplyCharges": "` + opts.supply + `",
+			"rateBlockUType": "singleRate",
+			"singleRate": {"displayName": "Usage", "rates": [{"unitPrice": "10"}]}
+		}]`
+	if extra != "" {
+		contract = contract + ", " + extra
+	}
+	contract = contract + "}"
+	fields := []string{`"planId": "` + opts.id + `"`}
+	for _, fuel := range opts.fuels {
+		fields = append(fields, `"`+fuel+`Contract": `+contract)
+	}
+	if opts.geography != "" {
+		fields = append(fields, `"geography": `+opts.geography)
+	}
+	plan := &cdsenergy.EnergyPlanDetail{}
+	err := json.Unmarshal([]byte("{"+strings.Join(fields, ",")+"}"), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+// Builds readings of kWh every hour, in UTC like the parser's, for each day from from up to to
+func hourlyUsage(from, to time.Time, kWh float64) nem12.UsageData {
+	var readings []nem12.HourlyReading
+	for hour := from; hour.Before(to); hour = hour.Add(time.Hour) {
+		readings = append(readings, nem12.HourlyReading{
+			StartTime:     hour,
+			EndTime:       hour.Add(time.Hour),
+			EnergyKWh:     kWh,
+			QualityMethod: []string{"A"},
+		})
+	}
+	return nem12.UsageData{"NMI1234567": {nem12.GeneralUsage: readings}}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestHorizonStartInAnotherTimezone(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+	// Still the 29th of February in UTC, but the date in Brisbane is what counts
+	brisbane := time.FixedZone("AEST", 10*60*60)
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, brisbane)
+
+	costs, err := calc.CalculateHorizon(usage, singleRatePlan(t, ""), start, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	if !cost.Start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got start %v, want midnight on 2024-03-01 UTC", cost.Start)
+	}
+	if cost.MissingDays != 0 {
+		t.Errorf("got %v missing days, want 0", cost.MissingDays)
+	}
+	if len(cost.Bills) != 1 {
+		t.Fatalf("got %v bills, want 1", len(cost.Bills))
+	}
+	bill := cost.Bills[0]
+	var supplyDays, kWh float64
+	for _, item := range bill.Items {
+		switch item.Unit {
+		case "days":
+			supplyDays = supplyDays + item.Quantity
+		case "kWh":
+			kWh = kWh + item.Quantity
+		}
+	}
+	if supplyDays != 31 || kWh != 31*24 {
+		t.Errorf("charged %v days of supply and %v kWh, want 31 days and %v kWh", supplyDays, kWh, 31*24)
+	}
+	want := 31*110 + 31*24*11.0
+	if !closeTo(bill.Total, want) {
+		t.Errorf("got a bill of %v, want %v", bill.Total, want)
+	}
+}
+
+func TestHorizonBenefitPeriodShorterThanTerm(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+	plan := singleRatePlan(t, `
+		"termType": "2_YEAR",
+		"benefitPeriod": "12 months",
+		"discounts": [{
+			"displayName": "10% off",
+			"type": "GUARANTEED",
+			"methodUType": "percentOfBill",
+			"percentOfBill": {"rate": "0.1"}
+		}]`)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	costs, err := calc.CalculateHorizon(usage, plan, start, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	if !cost.BenefitsEnd.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got benefits ending %v, want at the end of the 12 month benefit period", cost.BenefitsEnd)
+	}
+	if cost.ExitFee != 0 {
+		t.Errorf("got an exit fee of %v for staying the whole term", cost.ExitFee)
+	}
+	if len(cost.Bills) != 24 {
+		t.Fatalf("got %v bills, want 24", len(cost.Bills))
+	}
+	for i, bill := range cost.Bills {
+		discounted := false
+		for _, item := range bill.Items {
+			if item.Description == "10% off" {
+				discounted = true
+			}
+		}
+		if discounted != (i < 12) {
+			t.Errorf("bill %v starting %v discounted: %v", i, bill.Start.Format(time.DateOnly), discounted)
+		}
+	}
+	if cost.FirstYear >= cost.Ongoing {
+		t.Errorf("first year %v should be cheaper than ongoing %v with the discount", cost.FirstYear, cost.Ongoing)
+	}
+}
@@ -0,0 +1,92 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+// The component of an interval cost that's the daily supply charge rather than usage
+const SupplyComponent = "supply"
+
+// How a single reading was costed. The daily supply charge is included as its own entry at the
+// start of each day, spanning the whole day, so the entries for a day add up to the day's cost.
+type IntervalCost struct {
+	NMI       nem12.NMI
+	StartTime time.Time
+	EndTime   time.Time
+	// Zero for the daily supply charge
+	EnergyKWh float64
+	// The unit rate applied, including GST. For the daily supply charge this is the charge itself.
+	Rate float64
+	// The cost of the interval, including GST
+	Cost float64
+	// What the interval was charged as, e.g. "block 2" for the second rate block of a single rate
+	// plan, or SupplyComponent for the daily supply charge
+	Component string
+	// The display name of the plan's tariff period the interval fell into, or of the GreenPower
+	// charge for GreenPowerComponent
+	TariffPeriod string
+	// What the rate is per when the interval is put on a bill, i.e. "days" or "kWh". Empty if the
+	// cost is a single amount.
+	unit string
+}
+
+// Costs every reading individually, e.g. to chart costs or check them hour by hour against a bill.
+// The result is sorted by NMI and then by time. Readings are filtered by the quality policy in the
+// same way as for the other calculations.
+func (c *Calculator) CalculateIntervals(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail) ([]IntervalCost, error) {
+	c.explanation = &Explanation{}
+	c.explainDiscounts(plan)
+	c.explainConcessions()
+	var intervals []IntervalCost
+	for _, nmi := range sortedNMIs(usage) {
+		readings := c.applyQualityPolicy(nmi, usage[nmi][nem12.GeneralUsage])
+		days, err := c.costDays(nmi, readings, plan, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		for _, day := range days {
+			for _, interval := range day.intervals {
+				interval.NMI = nmi
+				intervals = append(intervals, interval)
+			}
+		}
+	}
+	if len(intervals) == 0 {
+		return nil, errors.New("no general usage readings to cost")
+	}
+	return intervals, nil
+}
+
+// Writes interval costs as CSV with a header row. Times are in RFC 3339 format.
+func WriteIntervalCosts(w io.Writer, intervals []IntervalCost) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"nmi", "startTime", "endTime", "energyKWh", "rate", "cost", "component", "tariffPeriod"})
+	if err != nil {
+		return err
+	}
+	for _, interval := range intervals {
+		err = writer.Write([]string{
+			string(interval.NMI),
+			interval.StartTime.Format(time.RFC3339),
+			interval.EndTime.Format(time.RFC3339),
+			strconv.FormatFloat(interval.EnergyKWh, 'f', -1, 64),
+			strconv.FormatFloat(interval.Rate, 'f', -1, 64),
+			strconv.FormatFloat(interval.Cost, 'f', -1, 64),
+			interval.Component,
+			interval.TariffPeriod,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,106 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+func TestCalculateIntervals(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+	house := hourlyUsage(from, to, 2)["NMI1234567"]
+	flat := hourlyUsage(from, to, 1)["NMI1234567"]
+	// The second day of the flat's readings is estimated
+	readings := flat[nem12.GeneralUsage]
+	for i := 24; i < len(readings); i++ {
+		readings[i].QualityMethod = []string{"E52"}
+	}
+	usage := nem12.UsageData{"NMI7654321": flat, "NMI1234567": house}
+	calc := NewCalculator(testLogger(), ActualOnly, 0, nil)
+
+	intervals, err := calc.CalculateIntervals(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A supply charge and 24 hours for each day, and only the actual day of the flat's
+	if len(intervals) != 3*25 {
+		t.Fatalf("got %v intervals, want %v", len(intervals), 3*25)
+	}
+	totals := make(map[nem12.NMI]float64)
+	for i, interval := range intervals {
+		totals[interval.NMI] = totals[interval.NMI] + interval.Cost
+		if i == 0 {
+			continue
+		}
+		previous := intervals[i-1]
+		if interval.NMI < previous.NMI || (interval.NMI == previous.NMI && interval.StartTime.Before(previous.StartTime)) {
+			t.Errorf("interval %v for %v at %v is out of order", i, interval.NMI, interval.StartTime)
+		}
+	}
+
+	// Each day starts with its supply charge, which spans the whole day
+	supply := intervals[25]
+	if supply.NMI != "NMI1234567" || supply.Component != SupplyComponent || !supply.StartTime.Equal(from.AddDate(0, 0, 1)) ||
+		supply.EndTime.Sub(supply.StartTime) != 24*time.Hour || supply.EnergyKWh != 0 || !closeTo(supply.Cost, 110) {
+		t.Errorf("got %+v for the second day's supply charge", supply)
+	}
+	usageCost := intervals[26]
+	if usageCost.Component != "block 1" || usageCost.TariffPeriod != "All year" || usageCost.EnergyKWh != 2 ||
+		!closeTo(usageCost.Rate, 11) || !closeTo(usageCost.Cost, 22) || usageCost.EndTime.Sub(usageCost.StartTime) != time.Hour {
+		t.Errorf("got %+v for the second day's first hour", usageCost)
+	}
+	// The intervals add up to the cost of each day
+	if want := 2 * (110 + 24*22.0); !closeTo(totals["NMI1234567"], want) {
+		t.Errorf("got a total of %v for the house, want %v", totals["NMI1234567"], want)
+	}
+	if want := 110 + 24*11.0; !closeTo(totals["NMI7654321"], want) {
+		t.Errorf("got a total of %v for the flat, want %v", totals["NMI7654321"], want)
+	}
+
+	_, err = calc.CalculateIntervals(nem12.UsageData{"NMI1234567": {nem12.Export: house[nem12.GeneralUsage]}}, singleRatePlan(t, ""))
+	if err == nil {
+		t.Error("costed usage data without any general usage")
+	}
+}
+
+func TestWriteIntervalCosts(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.FixedZone("AEST", 10*60*60))
+	intervals := []IntervalCost{
+		{NMI: "NMI1234567", StartTime: start, EndTime: start.AddDate(0, 0, 1), Rate: 110, Cost: 110, Component: SupplyComponent, TariffPeriod: "All year"},
+		{NMI: "NMI1234567", StartTime: start, EndTime: start.Add(time.Hour), EnergyKWh: 0.25, Rate: 22.5, Cost: 5.625, Component: "block 1", TariffPeriod: "All year"},
+	}
+	var written bytes.Buffer
+	err := WriteIntervalCosts(&written, intervals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "nmi,startTime,endTime,energyKWh,rate,cost,component,tariffPeriod\n" +
+		"NMI1234567,2023-01-01T00:00:00+10:00,2023-01-02T00:00:00+10:00,0,110,110,supply,All year\n" +
+		"NMI1234567,2023-01-01T00:00:00+10:00,2023-01-01T01:00:00+10:00,0.25,22.5,5.625,block 1,All year\n"
+	if written.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", written.String(), want)
+	}
+
+	// Costs straight from the calculator can be read back in
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), 1)
+	calculated, err := NewCalculator(testLogger(), IncludeEstimates, 0, nil).CalculateIntervals(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written.Reset()
+	err = WriteIntervalCosts(&written, calculated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&written).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(calculated)+1 {
+		t.Errorf("got %v rows, want a header and %v intervals", len(records), len(calculated))
+	}
+}
@@ -0,0 +1,184 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// The exact cost of usage between two dates. Nothing is extrapolated, so days in the range without
+// usage data aren't costed and are counted in MissingDays instead.
+type RangeCost struct {
+	// The first day costed
+	From time.Time
+	// The day after the last day costed
+	To    time.Time
+	Total float64
+	// The part of Total made up of daily supply charges
+	Supply float64
+	// The part of Total made up of usage charges
+	Usage float64
+	// The part of Total made up of GreenPower charges
+	GreenPower float64
+	KWh        float64
+	// Number of days in the range that were costed
+	Days int
+	// Number of days in the range without usage data to cost, including days left out because of
+	// the quality policy
+	MissingDays int
+}
+
+// Costs each NMI's usage from the start of the from day up to but not including the to day, keyed
+// by NMI. NMIs without any general usage readings are left out. Like CalculateMonthly, this doesn't
+// apply the household's concessions.
+func (c *Calculator) CalculateRange(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail, from, to time.Time) (map[nem12.NMI]RangeCost, error) {
+	c.explanation = &Explanation{}
+	c.explainDiscounts(plan)
+	c.explainConcessions()
+	from = util.StartOfDay(from)
+	to = util.StartOfDay(to)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("range start %v must be before its end %v", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	costs := make(map[nem12.NMI]RangeCost, len(usage))
+	for _, nmi := range sortedNMIs(usage) {
+		readings := usage[nmi][nem12.GeneralUsage]
+		if len(readings) == 0 {
+			continue
+		}
+		readings = c.applyQualityPolicy(nmi, readingsBetween(readings, from, to))
+		days, err := c.costDays(nmi, readings, plan, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't cost NMI %v: %w", nmi, err)
+		}
+		cost := RangeCost{From: from, To: to}
+		for _, day := range days {
+			cost.Supply = cost.Supply + day.supply
+			cost.Usage = cost.Usage + day.usage
+			cost.GreenPower = cost.GreenPower + day.green
+			cost.KWh = cost.KWh + day.kWh
+			cost.Days = cost.Days + 1
+		}
+		cost.Total = cost.Supply + cost.Usage + cost.GreenPower
+		cost.MissingDays = daysBetween(from, to) - cost.Days
+		costs[nmi] = cost
+	}
+	if len(costs) == 0 {
+		return nil, errors.New("no general usage readings to cost")
+	}
+	return costs, nil
+}
+
+// Gets the sorted readings that start on or after from and before to
+func readingsBetween(readings []nem12.HourlyReading, from, to time.Time) []nem12.HourlyReading {
+	start := len(readings)
+	for i, reading := range readings {
+		if !reading.StartTime.Before(from) {
+			start = i
+			break
+		}
+	}
+	end := start
+	for end < len(readings) && readings[end].StartTime.Before(to) {
+		end = end + 1
+	}
+	return readings[start:end]
+}
+
+// Counts calendar days rather than dividing by 24 hours, so it's right across daylight saving changes
+func daysBetween(from, to time.Time) int {
+	days := 0
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = days + 1
+	}
+	return days
+}
+
+// Estimates the cost of a full year from the average cost of each month. A month without data is
+// filled in from the average daily cost of the other months in the same season, or if the whole
+// season is missing, by interpolating between the nearest months either side that have data. This
+// keeps the estimate's seasonal shape, e.g. a missing July looks like June and August rather than
+// like the year as a whole. Returns the estimate and the months that were filled in.
+func annualise(perMonth []float64, hasData []bool) (float64, []filledMonth) {
+	daily := make([]float64, 12)
+	found := false
+	for i := range perMonth {
+		if hasData[i] {
+			daily[i] = perMonth[i] / float64(daysInMonth(i))
+			found = true
+		}
+	}
+	if !found {
+		return 0, nil
+	}
+	total := 0.0
+	var filled []filledMonth
+	for i := range perMonth {
+		if hasData[i] {
+			total = total + perMonth[i]
+			continue
+		}
+		estimate, ok := seasonalDailyCost(daily, hasData, i)
+		if !ok {
+			estimate = interpolatedDailyCost(daily, hasData, i)
+		}
+		total = total + estimate*float64(daysInMonth(i))
+		filled = append(filled, filledMonth{month: time.Month(i + 1), dailyCost: estimate, seasonal: ok})
+	}
+	return total, filled
+}
+
+// A month without data that was filled in to estimate the cost of a full year
+type filledMonth struct {
+	month     time.Month
+	dailyCost float64
+	// Whether it was filled in from the same season rather than interpolated
+	seasonal bool
+}
+
+// The average daily cost of the months with data in the same season as the given month
+func seasonalDailyCost(daily []float64, hasData []bool, month int) (float64, bool) {
+	sum := 0.0
+	count := 0
+	for i := range daily {
+		if hasData[i] && season(i) == season(month) {
+			sum = sum + daily[i]
+			count = count + 1
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// Linearly interpolates the daily cost between the nearest months with data before and after the
+// given month, wrapping around the end of the year. There must be at least one month with data.
+func interpolatedDailyCost(daily []float64, hasData []bool, month int) float64 {
+	before := 1
+	for !hasData[(month-before+12)%12] {
+		before = before + 1
+	}
+	after := 1
+	for !hasData[(month+after)%12] {
+		after = after + 1
+	}
+	beforeCost := daily[(month-before+12)%12]
+	afterCost := daily[(month+after)%12]
+	return (beforeCost*float64(after) + afterCost*float64(before)) / float64(before+after)
+}
+
+// Groups the months (indexed from January being 0) into seasons: December to February, March to
+// May, June to August and September to November
+func season(month int) int {
+	return (month + 1) % 12 / 3
+}
+
+// The number of days in a month indexed from January being 0, ignoring leap years
+func daysInMonth(month int) int {
+	return util.DaysInMonth(time.Date(2001, time.Month(month+1), 1, 0, 0, 0, 0, time.UTC))
+}
@@ -0,0 +1,105 @@
+package calculator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+func TestCalculateRange(t *testing.T) {
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC), 1)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	costs, err := calc.CalculateRange(usage, singleRatePlan(t, ""),
+		time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	if cost.Days != 7 || cost.MissingDays != 20 {
+		t.Errorf("costed %v days with %v missing, want 7 and 20", cost.Days, cost.MissingDays)
+	}
+	if !closeTo(cost.KWh, 7*24) || !closeTo(cost.Supply, 7*110) || !closeTo(cost.Usage, 7*24*11) {
+		t.Errorf("got %v kWh, supply %v and usage %v", cost.KWh, cost.Supply, cost.Usage)
+	}
+	if !closeTo(cost.Total, cost.Supply+cost.Usage) {
+		t.Errorf("got total %v, want supply plus usage", cost.Total)
+	}
+
+	_, err = calc.CalculateRange(usage, singleRatePlan(t, ""),
+		time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err == nil {
+		t.Error("accepted a range that ends before it starts")
+	}
+}
+
+func TestAnnualise(t *testing.T) {
+	// A daily cost of 1 all year, except 2 in June and 4 in August
+	perMonth := make([]float64, 12)
+	for i := range perMonth {
+		perMonth[i] = float64(daysInMonth(i))
+	}
+	perMonth[5] = 2 * 30
+	perMonth[7] = 4 * 31
+	hasData := make([]bool, 12)
+	for i := range hasData {
+		hasData[i] = true
+	}
+
+	// July comes from the rest of winter
+	hasData[6] = false
+	total, filled := annualise(perMonth, hasData)
+	if len(filled) != 1 || filled[0].month != time.July || !filled[0].seasonal || !closeTo(filled[0].dailyCost, 3) {
+		t.Errorf("got filled months %+v, want July from the rest of winter", filled)
+	}
+	want := 365 - 92 + 2*30.0 + 3*31 + 4*31
+	if !closeTo(total, want) {
+		t.Errorf("got %v, want %v", total, want)
+	}
+
+	// Without any of winter, it's interpolated from May and September, which both cost 1 a day
+	hasData[5] = false
+	hasData[7] = false
+	total, filled = annualise(perMonth, hasData)
+	if len(filled) != 3 || filled[0].seasonal || !closeTo(filled[0].dailyCost, 1) {
+		t.Errorf("got filled months %+v, want winter interpolated", filled)
+	}
+	if !closeTo(total, 365) {
+		t.Errorf("got %v, want 365", total)
+	}
+
+	total, filled = annualise(perMonth, make([]bool, 12))
+	if total != 0 || filled != nil {
+		t.Errorf("got %v with filled months %+v from no data", total, filled)
+	}
+}
+
+func TestCalculateMonthlyAnnualises(t *testing.T) {
+	// 2023 without July
+	usage := hourlyUsage(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), 1)
+	august := hourlyUsage(time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1)
+	readings := append(usage["NMI1234567"][nem12.GeneralUsage], august["NMI1234567"][nem12.GeneralUsage]...)
+	usage["NMI1234567"][nem12.GeneralUsage] = readings
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	costs, err := calc.CalculateMonthly(usage, singleRatePlan(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := costs["NMI1234567"]
+	daily := 110 + 24*11.0
+	if !closeTo(cost.Annualised, 365*daily) {
+		t.Errorf("got an annualised cost of %v, want %v", cost.Annualised, 365*daily)
+	}
+	if !reflect.DeepEqual(cost.ExtrapolatedMonths, []time.Month{time.July}) {
+		t.Errorf("got extrapolated months %v, want July", cost.ExtrapolatedMonths)
+	}
+	if cost.AveragePerMonth[6] != 0 || !closeTo(cost.AveragePerMonth[1], 28*daily) {
+		t.Errorf("got monthly costs %v", cost.AveragePerMonth)
+	}
+	if cost.ActualShare != 1 {
+		t.Errorf("got an actual share of %v, want 1", cost.ActualShare)
+	}
+}
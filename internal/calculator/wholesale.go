@@ -0,0 +1,137 @@
+package calculator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+	"github.com/georgesolomos/enket/internal/aemo"
+	"github.com/georgesolomos/enket/internal/nem12"
+	"github.com/georgesolomos/enket/internal/util"
+)
+
+// The component of an interval cost that's passed through from the wholesale spot price
+const WholesaleComponent = "wholesale"
+
+// How a spot pass-through plan charges for energy on top of the fixed parts the plan describes
+type Wholesale struct {
+	// Spot prices for the site's region
+	Prices *aemo.Prices
+	// Scales up the energy bought on the wholesale market to cover losses on the network, i.e. the
+	// site's distribution and transmission loss factors multiplied together. 1 if there are none.
+	LossFactor float64
+	// The general usage of each NMI at the meter's own interval lengths, e.g. from
+	// File.IntervalReadings, so each interval can be charged its own spot price. Days that aren't
+	// here are charged the average spot price over each hour of their readings.
+	Intervals map[nem12.NMI][]nem12.IntervalReading
+}
+
+// Costs each NMI in the usage data against a plan that passes through the wholesale spot price,
+// keyed by NMI, in the same way as CalculateMonthly so it can be compared with regular plans. The
+// plan should describe the fixed parts, i.e. the supply charge and the network and retail fees per
+// kWh as its usage rates. Each of the meter's intervals is also charged the spot price over it, like
+// the retailer would. Days without spot prices for all of their intervals are left out and
+// extrapolated like any other missing data.
+//
+// The usage data is hourly, so days need to be in wholesale.Intervals to be charged interval by
+// interval. Days that aren't, or that had readings imputed, are charged the average spot price over
+// each hour instead. That's an estimate, as usage that lines up with a price spike costs more than
+// it says and usage that avoids one costs less.
+func (c *Calculator) CalculatePassThrough(usage nem12.UsageData, plan *cdsenergy.EnergyPlanDetail, wholesale Wholesale) (map[nem12.NMI]Cost, error) {
+	if wholesale.Prices == nil {
+		return nil, errors.New("no wholesale prices to cost with")
+	}
+	if wholesale.LossFactor <= 0 {
+		wholesale.LossFactor = 1
+	}
+	c.wholesale = &wholesale
+	defer func() {
+		c.wholesale = nil
+	}()
+	return c.CalculateMonthly(usage, plan)
+}
+
+// Adds the wholesale charge for each of the meter's intervals to its day, falling back to the
+// hourly readings for days without them. Days that the prices don't fully cover are left out.
+func (c *Calculator) addWholesale(nmi nem12.NMI, days []dayCost, readings []nem12.HourlyReading) []dayCost {
+	hourly := make(map[time.Time][]nem12.IntervalReading)
+	imputed := make(map[time.Time]bool)
+	for _, reading := range readings {
+		date := util.StartOfDay(reading.StartTime)
+		hourly[date] = append(hourly[date], nem12.IntervalReading{
+			StartTime: reading.StartTime,
+			EndTime:   reading.EndTime,
+			EnergyKWh: reading.EnergyKWh,
+		})
+		imputed[date] = imputed[date] || reading.Imputed
+	}
+	native := make(map[time.Time][]nem12.IntervalReading)
+	for _, interval := range c.wholesale.Intervals[nmi] {
+		date := util.StartOfDay(interval.StartTime)
+		native[date] = append(native[date], interval)
+	}
+
+	priced := make([]dayCost, 0, len(days))
+	unpriced := 0
+	hourlyDays := 0
+	kWh := 0.0
+	cost := 0.0
+	for _, day := range days {
+		// Imputed readings aren't in the file, so the file's intervals would leave them out
+		intervals, found := native[day.date]
+		useHourly := !found || imputed[day.date]
+		if useHourly {
+			intervals = hourly[day.date]
+		}
+		costs := make([]IntervalCost, 0, len(intervals))
+		dayWholesale := 0.0
+		ok := true
+		for _, interval := range intervals {
+			var rrp float64
+			rrp, ok = c.wholesale.Prices.Average(interval.StartTime, interval.EndTime)
+			if !ok {
+				break
+			}
+			// Spot prices are in $/MWh, which is a tenth of a cent per kWh
+			rate := util.WithGST(rrp / 10 * c.wholesale.LossFactor)
+			costs = append(costs, IntervalCost{
+				StartTime:    interval.StartTime,
+				EndTime:      interval.EndTime,
+				EnergyKWh:    interval.EnergyKWh,
+				Rate:         rate,
+				Cost:         interval.EnergyKWh * rate,
+				Component:    WholesaleComponent,
+				TariffPeriod: c.wholesale.Prices.Region,
+				unit:         "kWh",
+			})
+			dayWholesale = dayWholesale + interval.EnergyKWh*rate
+		}
+		if !ok {
+			unpriced = unpriced + 1
+			continue
+		}
+		if useHourly {
+			hourlyDays = hourlyDays + 1
+		}
+		day.wholesale = dayWholesale
+		day.intervals = append(day.intervals, costs...)
+		priced = append(priced, day)
+		kWh = kWh + day.kWh
+		cost = cost + dayWholesale
+	}
+	if unpriced > 0 {
+		from, to := c.wholesale.Prices.Span()
+		c.explain(nmi, WholesaleStep, "Left out %v days without %v spot prices for every interval (prices cover %v to %v)",
+			unpriced, c.wholesale.Prices.Region, from.Format(time.DateTime), to.Format(time.DateTime))
+	}
+	if kWh > 0 {
+		c.explain(nmi, WholesaleStep, "Passed through %v spot prices with a loss factor of %v, averaging %.2f per kWh including GST over %.1f kWh",
+			c.wholesale.Prices.Region, c.wholesale.LossFactor, cost/kWh, kWh)
+	}
+	if hourlyDays > 0 {
+		c.explain(nmi, WholesaleStep, "Charged %v days at the average spot price over each hour, as their readings were imputed "+
+			"or the meter's intervals weren't available. The retailer charges each interval at its own price, so usage "+
+			"during price spikes would cost more than this.", hourlyDays)
+	}
+	return priced
+}
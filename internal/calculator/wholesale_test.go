@@ -0,0 +1,103 @@
+package calculator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgesolomos/enket/internal/aemo"
+	"github.com/georgesolomos/enket/internal/nem12"
+)
+
+// Loads 30 minute spot prices from from up to to, with the first half of each hour at low and the
+// second half at high
+func halfHourlyPrices(t *testing.T, from, to time.Time, low, high float64) *aemo.Prices {
+	t.Helper()
+	lines := []string{"REGION,SETTLEMENTDATE,TOTALDEMAND,RRP,PERIODTYPE"}
+	for start := from; start.Before(to); start = start.Add(30 * time.Minute) {
+		rrp := low
+		if start.Minute() == 30 {
+			rrp = high
+		}
+		lines = append(lines, fmt.Sprintf("NSW1,%v,1000,%v,TRADE", start.Add(30*time.Minute).Format("2006/01/02 15:04:05"), rrp))
+	}
+	path := filepath.Join(t.TempDir(), "PRICE_AND_DEMAND_202301_NSW1.csv")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices, err := aemo.LoadPrices("NSW1", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prices
+}
+
+func TestCalculatePassThroughChargesEachInterval(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	usage := hourlyUsage(from, to, 1)
+	// Each hour's kWh is mostly used in its cheap first half. The meter's intervals are left out for
+	// the 2nd and the 3rd is imputed, so both of those are charged by the hour.
+	var intervals []nem12.IntervalReading
+	for start := from; start.Before(to); start = start.Add(30 * time.Minute) {
+		if start.Day() == 2 {
+			continue
+		}
+		kWh := 0.9
+		if start.Minute() == 30 {
+			kWh = 0.1
+		}
+		intervals = append(intervals, nem12.IntervalReading{StartTime: start, EndTime: start.Add(30 * time.Minute), EnergyKWh: kWh})
+	}
+	readings := usage["NMI1234567"][nem12.GeneralUsage]
+	for i := range readings {
+		if readings[i].StartTime.Day() == 3 {
+			readings[i].Imputed = true
+		}
+	}
+	// 10c and 100c a kWh
+	prices := halfHourlyPrices(t, from, to, 100, 1000)
+	calc := NewCalculator(testLogger(), IncludeEstimates, 0, nil)
+
+	costs, err := calc.CalculatePassThrough(usage, singleRatePlan(t, ""), Wholesale{
+		Prices:    prices,
+		Intervals: map[nem12.NMI][]nem12.IntervalReading{"NMI1234567": intervals},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := 110 + 24*11.0
+	byInterval := 24 * 1.1 * (0.9*10 + 0.1*100)
+	byHour := 24 * 1.1 * 55
+	want := 31*fixed + 29*byInterval + 2*byHour
+	if got := costs["NMI1234567"].AveragePerMonth[0]; !closeTo(got, want) {
+		t.Errorf("got %v for January, want %v", got, want)
+	}
+	explained := false
+	for _, step := range calc.Explanation().Steps {
+		if step.Kind == WholesaleStep && strings.HasPrefix(step.Text, "Charged 2 days at the average spot price over each hour") {
+			explained = true
+		}
+	}
+	if !explained {
+		t.Errorf("the explanation doesn't say which days were charged by the hour: %v", calc.Explanation().Text())
+	}
+
+	// Without spot prices for the last week, those days are left out and extrapolated
+	prices = halfHourlyPrices(t, from, to.AddDate(0, 0, -7), 100, 1000)
+	costs, err = calc.CalculatePassThrough(usage, singleRatePlan(t, ""), Wholesale{
+		Prices:    prices,
+		Intervals: map[nem12.NMI][]nem12.IntervalReading{"NMI1234567": intervals},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = 31 * (22*(fixed+byInterval) + 2*(fixed+byHour)) / 24
+	if got := costs["NMI1234567"].AveragePerMonth[0]; !closeTo(got, want) {
+		t.Errorf("got %v for January without the last week's prices, want %v", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package energyplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/georgesolomos/enket/api/cdsenergy"
+)
+
+type ConcessionFetcher struct {
+	logger *slog.Logger
+	client *cdsenergy.ClientWithResponses
+}
+
+// Creates a fetcher for a retailer's accounts concessions endpoint. Unlike plans, concessions are
+// customer data, so baseURL is the retailer's CDR base URL (ending in /cds-au/v1) and token is an
+// access token the customer has consented to.
+func NewConcessionFetcher(logger *slog.Logger, baseURL string, token string) (*ConcessionFetcher, error) {
+	auth := func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	c, err := cdsenergy.NewClientWithResponses(baseURL, cdsenergy.WithRequestEditorFn(auth))
+	if err != nil {
+		return nil, err
+	}
+	return &ConcessionFetcher{
+		logger: logger,
+		client: c,
+	}, nil
+}
+
+func (f *ConcessionFetcher) FetchConcessions(accountID string) ([]cdsenergy.EnergyConcession, error) {
+	params := &cdsenergy.GetConcessionsParams{
+		XV: "1",
+	}
+	resp, err := f.client.GetConcessionsWithResponse(context.Background(), accountID, params)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode() {
+	case 200:
+		return resp.JSON200.Data.Concessions, nil
+	case 400:
+		f.logger.Error("FetchConcessions bad request", slog.Any("errors", resp.JSON400.Errors))
+		return nil, errors.New("bad request")
+	case 404:
+		f.logger.Error("FetchConcessions not found", slog.Any("errors", resp.JSON404.Errors))
+		return nil, errors.New("not found")
+	case 406:
+		f.logger.Error("FetchConcessions not acceptable", slog.Any("errors", resp.JSON406.Errors))
+		return nil, errors.New("not acceptable")
+	default:
+		f.logger.Error(fmt.Sprintf("FetchConcessions unrecognised error code %v", resp.HTTPResponse.StatusCode))
+		return nil, fmt.Errorf("unrecognised status code %v", resp.HTTPResponse.StatusCode)
+	}
+}
@@ -26,7 +26,9 @@ func NewPlanFetcher(logger *slog.Logger, provider string) (*PlanFetcher, error)
 	}, nil
 }
 
-func (p *PlanFetcher) FetchAllPlans() ([]*cdsenergy.EnergyPlan, error) {
+// Fetches every current plan for the fuel type. Electricity and gas plans are fetched separately, as
+// they're costed against different usage.
+func (p *PlanFetcher) FetchAllPlans(fuelType cdsenergy.ListPlansParamsFuelType) ([]*cdsenergy.EnergyPlan, error) {
 	var checkStatusCode = func(resp *cdsenergy.ListPlansResponse) error {
 		switch resp.StatusCode() {
 		case 200:
@@ -46,7 +48,6 @@ func (p *PlanFetcher) FetchAllPlans() ([]*cdsenergy.EnergyPlan, error) {
 		}
 	}
 
-	fuelType := cdsenergy.ListPlansParamsFuelTypeELECTRICITY
 	effective := cdsenergy.ListPlansParamsEffectiveCURRENT
 	pageSize := 1000
 	page := 1
@@ -103,3 +104,24 @@ func (p *PlanFetcher) FetchPlan(planID string) (*cdsenergy.EnergyPlanDetail, err
 		return nil, nil
 	}
 }
+
+// Fetches the details of every current plan for the fuel type. This makes a request per plan, so
+// it can take a while.
+func (p *PlanFetcher) FetchAllPlanDetails(fuelType cdsenergy.ListPlansParamsFuelType) ([]*cdsenergy.EnergyPlanDetail, error) {
+	plans, err := p.FetchAllPlans(fuelType)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]*cdsenergy.EnergyPlanDetail, 0, len(plans))
+	for _, plan := range plans {
+		detail, err := p.FetchPlan(plan.PlanId)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fetch plan %v: %w", plan.PlanId, err)
+		}
+		if detail == nil {
+			continue
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
@@ -0,0 +1,80 @@
+package gas
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gas usage between two basic meter reads, as shown on a gas bill
+type Read struct {
+	// The date of the earlier read
+	Start time.Time
+	// The date of the later read. The usage covers the days up to but not including this one, so
+	// consecutive reads share a date.
+	End time.Time
+	// The energy used, in MJ. Bills usually show this alongside the volume in cubic metres.
+	MJ float64
+}
+
+// Gets the number of days the read covers
+func (r Read) Days() int {
+	return int(r.End.Sub(r.Start).Round(24*time.Hour) / (24 * time.Hour))
+}
+
+// Parses gas usage from a CSV file with a row for each read, giving the start date, end date (both
+// YYYY-MM-DD) and MJ used, e.g. 2023-01-01,2023-04-01,4500. A header row is skipped. The reads are
+// returned sorted and must not overlap.
+func ParseReads(r io.Reader) ([]Read, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = 3
+	csvReader.TrimLeadingSpace = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read gas usage: %w", err)
+	}
+	reads := make([]Read, 0, len(records))
+	for i, record := range records {
+		start, err := time.Parse(time.DateOnly, strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				// Header row
+				continue
+			}
+			return nil, fmt.Errorf("couldn't parse start date on row %v: %w", i+1, err)
+		}
+		end, err := time.Parse(time.DateOnly, strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse end date on row %v: %w", i+1, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("read on row %v ends on or before it starts", i+1)
+		}
+		mj, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse MJ on row %v: %w", i+1, err)
+		}
+		if mj < 0 {
+			return nil, fmt.Errorf("negative usage on row %v", i+1)
+		}
+		reads = append(reads, Read{Start: start, End: end, MJ: mj})
+	}
+	if len(reads) == 0 {
+		return nil, errors.New("no gas reads found")
+	}
+	sort.Slice(reads, func(i, j int) bool {
+		return reads[i].Start.Before(reads[j].Start)
+	})
+	for i := 1; i < len(reads); i++ {
+		if reads[i].Start.Before(reads[i-1].End) {
+			return nil, fmt.Errorf("gas reads from %v and %v overlap",
+				reads[i-1].Start.Format(time.DateOnly), reads[i].Start.Format(time.DateOnly))
+		}
+	}
+	return reads, nil
+}
@@ -0,0 +1,61 @@
+package gas
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReads(t *testing.T) {
+	// The reads are listed out of order, with spaces after the commas
+	reads, err := ParseReads(strings.NewReader(`Start,End,MJ
+2023-04-01, 2023-07-01, 6000
+2023-01-01, 2023-04-01, 4500.5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Read{
+		{Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), MJ: 4500.5},
+		{Start: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), MJ: 6000},
+	}
+	if len(reads) != len(want) {
+		t.Fatalf("got %+v, want %+v", reads, want)
+	}
+	for i := range want {
+		if reads[i] != want[i] {
+			t.Errorf("got %+v, want %+v", reads[i], want[i])
+		}
+	}
+	if reads[0].Days() != 90 || reads[1].Days() != 91 {
+		t.Errorf("got reads of %v and %v days, want 90 and 91", reads[0].Days(), reads[1].Days())
+	}
+
+	// Without a header, the first row is a read
+	reads, err = ParseReads(strings.NewReader("2023-01-01,2023-04-01,4500\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reads) != 1 {
+		t.Errorf("got %v reads, want 1", len(reads))
+	}
+}
+
+func TestParseReadsErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"overlapping":           "2023-01-01,2023-04-01,4500\n2023-03-01,2023-06-01,4500\n",
+		"ends before it starts": "2023-04-01,2023-01-01,4500\n",
+		"ends when it starts":   "2023-01-01,2023-01-01,0\n",
+		"negative usage":        "2023-01-01,2023-04-01,-1\n",
+		"bad date after header": "Start,End,MJ\n01/01/2023,2023-04-01,4500\n",
+		"bad end date":          "2023-01-01,April,4500\n",
+		"bad MJ":                "2023-01-01,2023-04-01,lots\n",
+		"missing MJ":            "2023-01-01,2023-04-01\n",
+		"only a header":         "Start,End,MJ\n",
+	} {
+		_, err := ParseReads(strings.NewReader(content))
+		if err == nil {
+			t.Errorf("%v: accepted the reads", name)
+		}
+	}
+}
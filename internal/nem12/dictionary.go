@@ -0,0 +1,178 @@
+package nem12
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The reason codes and quality methods from the MDFF specification, so we can explain data problems
+// in plain English rather than as opaque codes. Codes that aren't in the dictionary are still
+// described, just not as helpfully.
+var (
+	//go:embed reasoncodes.csv
+	reasonCodesCSV string
+	//go:embed qualitymethods.csv
+	qualityMethodsCSV string
+
+	reasonCodes    = loadReasonCodes()
+	qualityMethods = loadDictionary(qualityMethodsCSV)
+)
+
+// A broad grouping of reason codes by what caused the data problem
+type ReasonCategory string
+
+const (
+	// The meter couldn't be read, e.g. locked gate, dog on premises
+	AccessIssue    ReasonCategory = "access"
+	MeterFault     ReasonCategory = "meterFault"
+	MeterChange    ReasonCategory = "meterChange"
+	CustomerRead   ReasonCategory = "customerRead"
+	DataCorrection ReasonCategory = "dataCorrection"
+	// There was no supply at the premises, e.g. power outage, main switch off
+	SupplyIssue ReasonCategory = "supply"
+	// The meter was read but the consumption looked wrong, e.g. zero, negative or unusually high
+	UnusualConsumption ReasonCategory = "consumption"
+	OtherReason        ReasonCategory = "other"
+)
+
+type ReasonCodeInfo struct {
+	Code        int
+	Description string
+	Category    ReasonCategory
+}
+
+// Looks up a reason code in the dictionary, returning false if we don't know it
+func LookupReasonCode(code int) (ReasonCodeInfo, bool) {
+	info, ok := reasonCodes[code]
+	return info, ok
+}
+
+func DescribeReasonCode(code int) string {
+	info, ok := reasonCodes[code]
+	if !ok {
+		return fmt.Sprintf("Unknown reason code %v", code)
+	}
+	return info.Description
+}
+
+// Describes a quality method such as A, E52 or S14. The first character is the quality flag and
+// the (optional) number after it is how the data was estimated or substituted.
+func DescribeQualityMethod(method string) string {
+	if method == "" {
+		return "No quality method"
+	}
+	flag, ok := qualityMethods[method[:1]]
+	if !ok {
+		return fmt.Sprintf("Unknown quality method %v", method)
+	}
+	if len(method) == 1 {
+		return flag
+	}
+	how, ok := qualityMethods[method[1:]]
+	if !ok {
+		return fmt.Sprintf("%v using unknown method %v", flag, method[1:])
+	}
+	return fmt.Sprintf("%v using %v", flag, how)
+}
+
+// Counts of the non-actual quality methods and reason codes in a set of readings
+type QualitySummary struct {
+	// Number of hourly readings with each quality method that isn't an actual read, e.g. E52
+	Methods map[string]int
+	// Number of hourly readings with each reason code
+	ReasonCodes map[int]int
+}
+
+func (d UsageData) QualitySummary() *QualitySummary {
+	summary := &QualitySummary{
+		Methods:     make(map[string]int),
+		ReasonCodes: make(map[int]int),
+	}
+	for _, readingTypes := range d {
+		for _, readings := range readingTypes {
+			for _, reading := range readings {
+				for _, method := range reading.QualityMethod {
+					if method == "" || method[0] != Actual {
+						summary.Methods[method] = summary.Methods[method] + 1
+					}
+				}
+				for _, code := range reading.ReasonCode {
+					summary.ReasonCodes[code] = summary.ReasonCodes[code] + 1
+				}
+			}
+		}
+	}
+	return summary
+}
+
+// Explains the summary in plain English, one line per quality method and reason code, with the most
+// common first
+func (s *QualitySummary) Explain() []string {
+	type line struct {
+		text  string
+		count int
+	}
+	lines := make([]line, 0, len(s.Methods)+len(s.ReasonCodes))
+	for method, count := range s.Methods {
+		lines = append(lines, line{fmt.Sprintf("%v hours of %v (%v)", count, DescribeQualityMethod(method), method), count})
+	}
+	for code, count := range s.ReasonCodes {
+		text := fmt.Sprintf("%v hours with reason code %v: %v", count, code, DescribeReasonCode(code))
+		if info, ok := reasonCodes[code]; ok {
+			text = fmt.Sprintf("%v (%v)", text, info.Category)
+		}
+		lines = append(lines, line{text, count})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].count != lines[j].count {
+			return lines[i].count > lines[j].count
+		}
+		return lines[i].text < lines[j].text
+	})
+	explanation := make([]string, len(lines))
+	for i, l := range lines {
+		explanation[i] = l.text
+	}
+	return explanation
+}
+
+func loadReasonCodes() map[int]ReasonCodeInfo {
+	records := readEmbeddedCSV(reasonCodesCSV)
+	codes := make(map[int]ReasonCodeInfo, len(records))
+	for _, record := range records {
+		code, err := strconv.Atoi(record[0])
+		if err != nil {
+			panic(fmt.Sprintf("invalid reason code in dictionary: %v", record[0]))
+		}
+		codes[code] = ReasonCodeInfo{
+			Code:        code,
+			Description: record[1],
+			Category:    ReasonCategory(record[2]),
+		}
+	}
+	return codes
+}
+
+func loadDictionary(data string) map[string]string {
+	records := readEmbeddedCSV(data)
+	dictionary := make(map[string]string, len(records))
+	for _, record := range records {
+		dictionary[record[0]] = record[1]
+	}
+	return dictionary
+}
+
+// The embedded files are part of the build, so if they can't be read there's nothing sensible to do
+// but panic
+func readEmbeddedCSV(data string) [][]string {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded dictionary: %v", err))
+	}
+	// Skip the header row
+	return records[1:]
+}
@@ -0,0 +1,90 @@
+package nem12
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDescribeQualityMethod(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		want   string
+	}{
+		{"A", "Actual read"},
+		{"E52", "Forward estimate using previous read"},
+		{"S14", "Substitute using like day"},
+		{"F", "Final substitute"},
+		{"E99", "Forward estimate using unknown method 99"},
+		{"X1", "Unknown quality method X1"},
+		{"", "No quality method"},
+	} {
+		if got := DescribeQualityMethod(tc.method); got != tc.want {
+			t.Errorf("got %q for %q, want %q", got, tc.method, tc.want)
+		}
+	}
+}
+
+func TestReasonCodes(t *testing.T) {
+	info, ok := LookupReasonCode(53)
+	if !ok || info.Code != 53 || info.Description != "Key required" || info.Category != AccessIssue {
+		t.Errorf("got %+v for reason code 53", info)
+	}
+	if _, ok := LookupReasonCode(999); ok {
+		t.Error("found reason code 999, which isn't in the specification")
+	}
+	if got := DescribeReasonCode(1); got != "Meter or equipment changed" {
+		t.Errorf("got %q for reason code 1", got)
+	}
+	if got := DescribeReasonCode(999); got != "Unknown reason code 999" {
+		t.Errorf("got %q for reason code 999", got)
+	}
+
+	// A typo in the dictionary's categories would quietly put codes in a group of their own
+	categories := []ReasonCategory{AccessIssue, MeterFault, MeterChange, CustomerRead, DataCorrection,
+		SupplyIssue, UnusualConsumption, OtherReason}
+	for code, info := range reasonCodes {
+		if !slices.Contains(categories, info.Category) {
+			t.Errorf("reason code %v has unknown category %q", code, info.Category)
+		}
+	}
+}
+
+func TestQualitySummary(t *testing.T) {
+	reading := func(methods []string, codes ...int) HourlyReading {
+		return HourlyReading{QualityMethod: methods, ReasonCode: codes}
+	}
+	data := UsageData{
+		"NMI1234567": {
+			GeneralUsage: {
+				reading([]string{"A"}),
+				reading([]string{"E52"}, 53),
+				reading([]string{"E52"}, 53),
+				reading([]string{"S14"}, 1),
+				// An hour made up of an actual and an estimated interval
+				reading([]string{"A", "E52"}, 53),
+			},
+			Export: {
+				reading([]string{"E52"}, 999),
+				reading([]string{"A"}),
+			},
+		},
+	}
+	summary := data.QualitySummary()
+	if len(summary.Methods) != 2 || summary.Methods["E52"] != 4 || summary.Methods["S14"] != 1 {
+		t.Errorf("got quality methods %v, want 4 E52 and 1 S14", summary.Methods)
+	}
+	if len(summary.ReasonCodes) != 3 || summary.ReasonCodes[53] != 3 || summary.ReasonCodes[1] != 1 || summary.ReasonCodes[999] != 1 {
+		t.Errorf("got reason codes %v", summary.ReasonCodes)
+	}
+
+	want := []string{
+		"4 hours of Forward estimate using previous read (E52)",
+		"3 hours with reason code 53: Key required (access)",
+		"1 hours of Substitute using like day (S14)",
+		"1 hours with reason code 1: Meter or equipment changed (meterChange)",
+		"1 hours with reason code 999: Unknown reason code 999",
+	}
+	if got := summary.Explain(); !slices.Equal(got, want) {
+		t.Errorf("got explanation %q, want %q", got, want)
+	}
+}
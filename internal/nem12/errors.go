@@ -0,0 +1,42 @@
+package nem12
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Used as the field index of a ParseError when the error applies to the whole record
+const wholeRecord = -1
+
+var (
+	errMissingField = errors.New("missing mandatory field")
+)
+
+// An error (or warning) found while parsing a NEM12 file, with enough detail to find the offending
+// value in the original file
+type ParseError struct {
+	// 1-indexed line number of the record in the file
+	Line int
+	// The record indicator (e.g. 300) of the record, or 0 if it couldn't be determined
+	RecordIndicator int
+	// 0-indexed position of the offending field within the record, or -1 if the error applies to
+	// the whole record
+	Field int
+	// The raw value of the offending field, if there is one
+	Value string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.RecordIndicator == 0 {
+		return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+	}
+	if e.Field == wholeRecord {
+		return fmt.Sprintf("line %v: %v record: %v", e.Line, e.RecordIndicator, e.Err)
+	}
+	return fmt.Sprintf("line %v: %v record field %v (%q): %v", e.Line, e.RecordIndicator, e.Field, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,105 @@
+package nem12
+
+import (
+	"sort"
+	"time"
+)
+
+// A run of consecutive days with no interval data for an NMI and suffix
+type Gap struct {
+	NMI    string
+	Suffix string
+	// The first and last missing days, inclusive
+	From time.Time
+	To   time.Time
+}
+
+// Number of whole days in the gap
+func (g Gap) Days() int {
+	return int(g.To.Sub(g.From).Hours()/24) + 1
+}
+
+// Finds the days missing between the first and last interval of each NMI and suffix. Gaps are
+// ordered by NMI, suffix, then date.
+func (f *File) Gaps() []Gap {
+	type streamKey struct {
+		nmi    string
+		suffix string
+	}
+	dates := make(map[streamKey][]time.Time)
+	for _, block := range f.Blocks {
+		key := streamKey{block.Details.NMI, block.Details.NMISuffix}
+		for _, interval := range block.Intervals {
+			dates[key] = append(dates[key], interval.IntervalDate)
+		}
+	}
+
+	gaps := make([]Gap, 0)
+	for key, days := range dates {
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		for i := 1; i < len(days); i++ {
+			expected := days[i-1].AddDate(0, 0, 1)
+			if days[i].After(expected) {
+				gaps = append(gaps, Gap{
+					NMI:    key.nmi,
+					Suffix: key.suffix,
+					From:   expected,
+					To:     days[i].AddDate(0, 0, -1),
+				})
+			}
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].NMI != gaps[j].NMI {
+			return gaps[i].NMI < gaps[j].NMI
+		}
+		if gaps[i].Suffix != gaps[j].Suffix {
+			return gaps[i].Suffix < gaps[j].Suffix
+		}
+		return gaps[i].From.Before(gaps[j].From)
+	})
+	return gaps
+}
+
+// A run of missing hourly readings for an NMI and reading type
+type ReadingGap struct {
+	NMI         NMI
+	ReadingType ReadingType
+	// The start of the first missing hour and the end of the last one
+	Start time.Time
+	End   time.Time
+}
+
+func (g ReadingGap) Hours() int {
+	return int(g.End.Sub(g.Start).Hours())
+}
+
+// Finds the hours missing between the first and last reading of each NMI and reading type. Gaps
+// are ordered by NMI, reading type, then time.
+func (d UsageData) Gaps() []ReadingGap {
+	gaps := make([]ReadingGap, 0)
+	for nmi, readingTypes := range d {
+		for readingType, readings := range readingTypes {
+			for i := 1; i < len(readings); i++ {
+				if readings[i].StartTime.After(readings[i-1].EndTime) {
+					gaps = append(gaps, ReadingGap{
+						NMI:         nmi,
+						ReadingType: readingType,
+						Start:       readings[i-1].EndTime,
+						End:         readings[i].StartTime,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].NMI != gaps[j].NMI {
+			return gaps[i].NMI < gaps[j].NMI
+		}
+		if gaps[i].ReadingType != gaps[j].ReadingType {
+			return gaps[i].ReadingType < gaps[j].ReadingType
+		}
+		return gaps[i].Start.Before(gaps[j].Start)
+	})
+	return gaps
+}
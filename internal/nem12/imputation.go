@@ -0,0 +1,145 @@
+package nem12
+
+import (
+	"sort"
+	"time"
+)
+
+// A way of estimating the readings missing from a gap
+type ImputationStrategy interface {
+	// Estimates the energy for each missing hour in the gap, in order. The readings are all the
+	// existing readings for the gap's NMI and reading type in chronological order. Returns false if
+	// there isn't enough data to fill the gap.
+	Impute(readings []HourlyReading, gap ReadingGap) ([]float64, bool)
+}
+
+// Fills every gap in the usage data using the given strategy. The readings that are made up are
+// marked as imputed. Returns the number of hours imputed and the gaps that couldn't be filled.
+func (d UsageData) Impute(strategy ImputationStrategy) (int, []ReadingGap) {
+	imputed := 0
+	unfilled := make([]ReadingGap, 0)
+	// Estimates are only ever based on real readings, not on ones imputed for an earlier gap
+	original := make(map[NMI]map[ReadingType][]HourlyReading)
+	for nmi, readingTypes := range d {
+		original[nmi] = make(map[ReadingType][]HourlyReading)
+		for readingType, readings := range readingTypes {
+			original[nmi][readingType] = readings
+		}
+	}
+	for _, gap := range d.Gaps() {
+		energy, ok := strategy.Impute(original[gap.NMI][gap.ReadingType], gap)
+		if !ok {
+			unfilled = append(unfilled, gap)
+			continue
+		}
+		readings := d[gap.NMI][gap.ReadingType]
+		for i, kWh := range energy {
+			start := gap.Start.Add(time.Duration(i) * time.Hour)
+			readings = append(readings, HourlyReading{
+				StartTime: start,
+				EndTime:   start.Add(time.Hour),
+				EnergyKWh: kWh,
+				Imputed:   true,
+			})
+		}
+		imputed = imputed + len(energy)
+		d[gap.NMI][gap.ReadingType] = readings
+	}
+	for _, readingTypes := range d {
+		for readingType, readings := range readingTypes {
+			sort.SliceStable(readings, func(i, j int) bool { return readings[i].StartTime.Before(readings[j].StartTime) })
+			readingTypes[readingType] = readings
+		}
+	}
+	return imputed, unfilled
+}
+
+// Fills each missing hour with the average of the same hour on the same day of the week in the
+// surrounding weeks
+type SameWeekdayAverage struct {
+	// How many weeks either side of the missing hour to look at
+	Weeks int
+}
+
+func (s SameWeekdayAverage) Impute(readings []HourlyReading, gap ReadingGap) ([]float64, bool) {
+	energyByHour := indexByStartTime(readings)
+	energy := make([]float64, 0, gap.Hours())
+	for hour := gap.Start; hour.Before(gap.End); hour = hour.Add(time.Hour) {
+		total := 0.0
+		count := 0
+		for week := -s.Weeks; week <= s.Weeks; week++ {
+			if kWh, ok := energyByHour[hour.AddDate(0, 0, 7*week).Unix()]; ok {
+				total = total + kWh
+				count = count + 1
+			}
+		}
+		if count == 0 {
+			return nil, false
+		}
+		energy = append(energy, total/float64(count))
+	}
+	return energy, true
+}
+
+// Fills each missing hour with the average of the same hour of the day across every reading in
+// the same calendar month, from any year
+type SeasonalProfile struct{}
+
+func (s SeasonalProfile) Impute(readings []HourlyReading, gap ReadingGap) ([]float64, bool) {
+	type profileKey struct {
+		month time.Month
+		hour  int
+	}
+	totals := make(map[profileKey]float64)
+	counts := make(map[profileKey]int)
+	for _, reading := range readings {
+		key := profileKey{reading.StartTime.Month(), reading.StartTime.Hour()}
+		totals[key] = totals[key] + reading.EnergyKWh
+		counts[key] = counts[key] + 1
+	}
+	energy := make([]float64, 0, gap.Hours())
+	for hour := gap.Start; hour.Before(gap.End); hour = hour.Add(time.Hour) {
+		key := profileKey{hour.Month(), hour.Hour()}
+		if counts[key] == 0 {
+			return nil, false
+		}
+		energy = append(energy, totals[key]/float64(counts[key]))
+	}
+	return energy, true
+}
+
+// Fills the missing hours by drawing a straight line between the readings either side of the gap.
+// Best suited to short gaps.
+type NeighbourInterpolation struct{}
+
+func (n NeighbourInterpolation) Impute(readings []HourlyReading, gap ReadingGap) ([]float64, bool) {
+	energyByHour := indexByStartTime(readings)
+	before, hasBefore := energyByHour[gap.Start.Add(-time.Hour).Unix()]
+	after, hasAfter := energyByHour[gap.End.Unix()]
+	if !hasBefore && !hasAfter {
+		return nil, false
+	}
+	if !hasBefore {
+		before = after
+	}
+	if !hasAfter {
+		after = before
+	}
+	hours := gap.Hours()
+	energy := make([]float64, hours)
+	for i := range energy {
+		// The neighbours sit at positions 0 and hours+1, with the missing hours in between
+		fraction := float64(i+1) / float64(hours+1)
+		energy[i] = before + (after-before)*fraction
+	}
+	return energy, true
+}
+
+// Maps each reading's start time (as a Unix timestamp) to its energy
+func indexByStartTime(readings []HourlyReading) map[int64]float64 {
+	index := make(map[int64]float64, len(readings))
+	for _, reading := range readings {
+		index[reading.StartTime.Unix()] = reading.EnergyKWh
+	}
+	return index
+}
@@ -0,0 +1,87 @@
+package nem12
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Builds actual readings every hour from from up to to, with the energy for each hour coming from fn
+func readingsBetween(from, to time.Time, fn func(time.Time) float64) []HourlyReading {
+	var readings []HourlyReading
+	for hour := from; hour.Before(to); hour = hour.Add(time.Hour) {
+		readings = append(readings, HourlyReading{
+			StartTime:     hour,
+			EndTime:       hour.Add(time.Hour),
+			EnergyKWh:     fn(hour),
+			QualityMethod: []string{"A"},
+		})
+	}
+	return readings
+}
+
+func TestImputeFillsGaps(t *testing.T) {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	gapStart := start.AddDate(0, 0, 14)
+	gapEnd := gapStart.Add(3 * time.Hour)
+	// Each week uses more than the last, so the average of the weeks either side is the week in between
+	weekly := func(hour time.Time) float64 { return float64(int(hour.Sub(start).Hours()) / (7 * 24)) }
+	readings := append(readingsBetween(start, gapStart, weekly), readingsBetween(gapEnd, start.AddDate(0, 0, 28), weekly)...)
+
+	for _, tc := range []struct {
+		name     string
+		strategy ImputationStrategy
+		want     []float64
+	}{
+		{"same weekday", SameWeekdayAverage{Weeks: 1}, []float64{2, 2, 2}},
+		// The same hours on the other 27 days in January, which are 7 days each of 0, 1 and 3 and 6
+		// days of 2
+		{"seasonal", SeasonalProfile{}, []float64{40.0 / 27, 40.0 / 27, 40.0 / 27}},
+		// Straight from the 1 before the gap to the 2 after it
+		{"neighbours", NeighbourInterpolation{}, []float64{1.25, 1.5, 1.75}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := UsageData{"NMI1234567": {GeneralUsage: append([]HourlyReading(nil), readings...)}}
+			imputed, unfilled := data.Impute(tc.strategy)
+			if imputed != 3 || len(unfilled) != 0 {
+				t.Fatalf("imputed %v hours with %v gaps unfilled, want 3 and none", imputed, len(unfilled))
+			}
+			filled := data["NMI1234567"][GeneralUsage]
+			if len(filled) != len(readings)+3 || len(data.Gaps()) != 0 {
+				t.Fatalf("gap wasn't filled")
+			}
+			for i, want := range tc.want {
+				reading := filled[len(readingsBetween(start, gapStart, weekly))+i]
+				if !reading.StartTime.Equal(gapStart.Add(time.Duration(i)*time.Hour)) || !reading.Imputed {
+					t.Fatalf("got reading %+v in the gap", reading)
+				}
+				if math.Abs(reading.EnergyKWh-want) > 1e-9 {
+					t.Errorf("imputed %v kWh at %v, want %v", reading.EnergyKWh, reading.StartTime, want)
+				}
+				if reading.IsActual() {
+					t.Errorf("imputed reading counts as an actual read")
+				}
+			}
+		})
+	}
+}
+
+func TestImputeLeavesGapsWithoutData(t *testing.T) {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	one := func(time.Time) float64 { return 1 }
+	// There's nothing a week either side of a gap in the middle of a fortnight
+	readings := append(readingsBetween(start, start.AddDate(0, 0, 7), one),
+		readingsBetween(start.AddDate(0, 0, 7).Add(time.Hour), start.AddDate(0, 0, 8), one)...)
+	data := UsageData{"NMI1234567": {GeneralUsage: readings}}
+
+	imputed, unfilled := data.Impute(SameWeekdayAverage{Weeks: 0})
+	if imputed != 0 || len(unfilled) != 1 {
+		t.Fatalf("imputed %v hours with %v gaps unfilled, want none and 1", imputed, len(unfilled))
+	}
+	if !unfilled[0].Start.Equal(start.AddDate(0, 0, 7)) || unfilled[0].Hours() != 1 {
+		t.Errorf("got unfilled gap %+v", unfilled[0])
+	}
+	if len(data["NMI1234567"][GeneralUsage]) != len(readings) {
+		t.Errorf("readings were added for a gap that couldn't be filled")
+	}
+}
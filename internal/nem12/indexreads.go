@@ -0,0 +1,71 @@
+package nem12
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Compares the change in a register's index reads (from 500 records) with the interval data
+// recorded over the same period. The two should agree, give or take the rounding of the reads.
+type IndexReadCheck struct {
+	NMI    string
+	Suffix string
+	From   time.Time
+	To     time.Time
+	// The change in the index read between From and To, in the block's unit of measure
+	IndexDelta float64
+	// The sum of the interval values starting between From and To, in the block's unit of measure
+	IntervalTotal float64
+}
+
+func (c IndexReadCheck) Difference() float64 {
+	return c.IntervalTotal - c.IndexDelta
+}
+
+// Checks each pair of consecutive index reads in every block against the interval data. B2B details
+// without an index read or read time can't be checked and are ignored.
+func (f *File) CheckIndexReads() []IndexReadCheck {
+	checks := make([]IndexReadCheck, 0)
+	for _, block := range f.Blocks {
+		type indexRead struct {
+			time  time.Time
+			value float64
+		}
+		reads := make([]indexRead, 0)
+		for _, b2b := range block.B2bDetails {
+			value, err := strconv.ParseFloat(b2b.IndexRead, 64)
+			if err != nil || b2b.ReadDataTime.IsZero() {
+				continue
+			}
+			reads = append(reads, indexRead{b2b.ReadDataTime, value})
+		}
+		sort.Slice(reads, func(i, j int) bool { return reads[i].time.Before(reads[j].time) })
+		for i := 1; i < len(reads); i++ {
+			checks = append(checks, IndexReadCheck{
+				NMI:           block.Details.NMI,
+				Suffix:        block.Details.NMISuffix,
+				From:          reads[i-1].time,
+				To:            reads[i].time,
+				IndexDelta:    reads[i].value - reads[i-1].value,
+				IntervalTotal: block.intervalTotal(reads[i-1].time, reads[i].time),
+			})
+		}
+	}
+	return checks
+}
+
+// Sums the interval values that start within [from, to)
+func (b *NMIDataBlock) intervalTotal(from, to time.Time) float64 {
+	intervalLength := time.Duration(b.Details.IntervalLength) * time.Minute
+	total := 0.0
+	for _, interval := range b.Intervals {
+		for i, val := range interval.IntervalValues {
+			start := interval.IntervalDate.Add(time.Duration(i) * intervalLength)
+			if !start.Before(from) && start.Before(to) {
+				total = total + val.Value
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,64 @@
+package nem12
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The energy measured over one of the meter's own intervals, e.g. 5 or 30 minutes
+type IntervalReading struct {
+	StartTime time.Time
+	EndTime   time.Time
+	EnergyKWh float64
+}
+
+// Gets the readings of the given type for each NMI at the interval length the meter recorded them
+// at, rather than summed into hours like UsageData. Where several suffixes map to the reading type,
+// each one's intervals are kept separate, so the same interval can appear more than once. Readings
+// are sorted by start time.
+//
+// Every interval in the file is used, so call Reconcile first if the same day might appear more
+// than once. If suffixes is nil, DefaultSuffixMapping is used.
+func (f *File) IntervalReadings(suffixes *SuffixMapping, readingType ReadingType) (map[NMI][]IntervalReading, error) {
+	if suffixes == nil {
+		suffixes = DefaultSuffixMapping()
+	}
+	readings := make(map[NMI][]IntervalReading)
+	for _, block := range f.Blocks {
+		blockType, ok := suffixes.ReadingType(block.Details.NMISuffix)
+		if !ok || blockType != readingType {
+			continue
+		}
+		nmi := NMI(block.Details.NMI)
+		err := block.eachInterval(func(start time.Time, length time.Duration, kWh float64) {
+			readings[nmi] = append(readings[nmi], IntervalReading{StartTime: start, EndTime: start.Add(length), EnergyKWh: kWh})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, nmiReadings := range readings {
+		sort.SliceStable(nmiReadings, func(i, j int) bool { return nmiReadings[i].StartTime.Before(nmiReadings[j].StartTime) })
+	}
+	return readings, nil
+}
+
+// Calls fn with the start, length and energy of each interval value in the block, converted to kWh
+func (b *NMIDataBlock) eachInterval(fn func(start time.Time, length time.Duration, kWh float64)) error {
+	if b.Details.IntervalLength <= 0 {
+		return fmt.Errorf("NMI %v suffix %v has an interval length of %v", b.Details.NMI,
+			b.Details.NMISuffix, b.Details.IntervalLength)
+	}
+	length := time.Duration(b.Details.IntervalLength) * time.Minute
+	for _, interval := range b.Intervals {
+		for i, value := range interval.IntervalValues {
+			kWh, err := convertEnergy(value.Value, b.Details.UOM)
+			if err != nil {
+				return fmt.Errorf("NMI %v suffix %v: %w", b.Details.NMI, b.Details.NMISuffix, err)
+			}
+			fn(interval.IntervalDate.Add(time.Duration(i)*length), length, kWh)
+		}
+	}
+	return nil
+}
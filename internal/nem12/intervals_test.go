@@ -0,0 +1,50 @@
+package nem12
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntervalReadings(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1E2,1,E1,N1,METER1,Wh,5,",
+		intervalRecord("20230102", 288, func(i int) float64 { return float64(i) }),
+		intervalRecord("20230101", 288, func(i int) float64 { return 100 }),
+		"200,NMI1234567,E1E2,2,E2,N1,METER1,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return 5 }),
+		"900",
+	)
+	parser := NewParser(testLogger(), strings.NewReader(content), Strict, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	readings, err := file.IntervalReadings(nil, GeneralUsage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Controlled load is its own reading type, so only the 5 minute general usage is included
+	nmiReadings := readings["NMI1234567"]
+	if len(nmiReadings) != 2*288 {
+		t.Fatalf("got %v readings, want one for each 5 minutes of both days", len(nmiReadings))
+	}
+	first := nmiReadings[0]
+	if !first.StartTime.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) || first.EndTime.Sub(first.StartTime) != 5*time.Minute || first.EnergyKWh != 0.1 {
+		t.Errorf("got %+v for the first interval, want 0.1 kWh over 5 minutes from midnight on the 1st", first)
+	}
+	last := nmiReadings[len(nmiReadings)-1]
+	if !last.StartTime.Equal(time.Date(2023, 1, 2, 23, 55, 0, 0, time.UTC)) || !closeEnough(last.EnergyKWh, 0.287) {
+		t.Errorf("got %+v for the last interval", last)
+	}
+
+	controlledLoad, err := file.IntervalReadings(nil, ControlledLoad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(controlledLoad["NMI1234567"]) != 48 || controlledLoad["NMI1234567"][0].EndTime.Sub(controlledLoad["NMI1234567"][0].StartTime) != 30*time.Minute {
+		t.Errorf("got controlled load readings %+v, want 30 minute intervals", controlledLoad["NMI1234567"])
+	}
+}
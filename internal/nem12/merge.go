@@ -0,0 +1,195 @@
+package nem12
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Combines several NEM12 files into one continuous dataset. Retailers and distributors often cap
+// exports at a few months, so a full year of usage can be spread across several files.
+type Merger struct {
+	logger   *slog.Logger
+	mode     ParseMode
+	suffixes *SuffixMapping
+	merged   *File
+	report   *MergeReport
+}
+
+type MergeReport struct {
+	// The report from parsing each source, keyed by its path. Files inside a zip archive are keyed
+	// as the archive path followed by the file's name within it.
+	Sources map[string]*ParseReport
+	// The report from combining the sources, covering intervals replaced by another source and
+	// blocks skipped when converting to readings. Line numbers refer to the block's own source.
+	Merged *ParseReport
+	// Days missing from the combined data
+	Gaps []Gap
+}
+
+// If suffixes is nil, DefaultSuffixMapping is used
+func NewMerger(logger *slog.Logger, mode ParseMode, suffixes *SuffixMapping) *Merger {
+	if suffixes == nil {
+		suffixes = DefaultSuffixMapping()
+	}
+	return &Merger{
+		logger:   logger,
+		mode:     mode,
+		suffixes: suffixes,
+		report:   &MergeReport{},
+	}
+}
+
+// Returns the report for the most recent call to Merge or MergeFiles
+func (m *Merger) Report() *MergeReport {
+	return m.report
+}
+
+// Returns the merged records from the most recent call to Merge or MergeFiles
+func (m *Merger) File() *File {
+	return m.merged
+}
+
+// Parses and merges every file at the given paths into hourly readings. Each path can be a NEM12
+// file, a zip archive of NEM12 files, or a directory containing either.
+func (m *Merger) Merge(ctx context.Context, paths ...string) (UsageData, error) {
+	file, err := m.MergeFiles(ctx, paths...)
+	if err != nil {
+		return nil, err
+	}
+	converter := &Parser{
+		logger:   m.logger,
+		mode:     m.mode,
+		suffixes: m.suffixes,
+		report:   m.report.Merged,
+	}
+	return converter.ToUsageData(file), nil
+}
+
+// Parses and merges every file at the given paths into a single set of records. Days that appear in
+// more than one file are resolved to their latest version as per File.Reconcile.
+func (m *Merger) MergeFiles(ctx context.Context, paths ...string) (*File, error) {
+	m.report = &MergeReport{
+		Sources: make(map[string]*ParseReport),
+		Merged:  &ParseReport{},
+	}
+	m.merged = nil
+	merged := &File{}
+	for _, path := range paths {
+		files, err := m.parsePath(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			// The header only describes a single file, so we keep the first one we find
+			if merged.Header == nil {
+				merged.Header = file.Header
+			}
+			merged.Blocks = append(merged.Blocks, file.Blocks...)
+		}
+	}
+	m.merged = merged
+	m.report.Merged.ReplacedIntervals = merged.Reconcile()
+	m.report.Gaps = merged.Gaps()
+	for _, gap := range m.report.Gaps {
+		m.logger.Warn(fmt.Sprintf("No data for NMI %v suffix %v from %v to %v", gap.NMI, gap.Suffix,
+			gap.From.Format(dateFormat), gap.To.Format(dateFormat)))
+	}
+	return merged, nil
+}
+
+func (m *Merger) parsePath(ctx context.Context, path string) ([]*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return m.parseDir(ctx, path)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return m.parseZip(ctx, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	file, err := m.parse(ctx, path, f)
+	if err != nil {
+		return nil, err
+	}
+	return []*File{file}, nil
+}
+
+// Parses every file directly within a directory in name order. Hidden files are ignored.
+func (m *Merger) parseDir(ctx context.Context, path string) ([]*File, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*File, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		parsed, err := m.parsePath(ctx, filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, parsed...)
+	}
+	return files, nil
+}
+
+// Parses every file within a zip archive in name order
+func (m *Merger) parseZip(ctx context.Context, path string) ([]*File, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	entries := make([]*zip.File, 0)
+	for _, entry := range archive.File {
+		if !entry.FileInfo().IsDir() {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	files := make([]*File, 0)
+	for _, entry := range entries {
+		r, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		// The parser needs to be able to seek, which zip entries can't do, so we read them into memory
+		contents, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		file, err := m.parse(ctx, filepath.Join(path, entry.Name), bytes.NewReader(contents))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (m *Merger) parse(ctx context.Context, name string, r io.ReadSeeker) (*File, error) {
+	parser := NewParser(m.logger, r, m.mode, m.suffixes)
+	file, err := parser.ParseFile(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	m.report.Sources[name] = parser.Report()
+	return file, nil
+}
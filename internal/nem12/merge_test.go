@@ -0,0 +1,129 @@
+package nem12
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	header := "100,NEM12,200301011534,MDP1,Retailer1"
+	details := "200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,"
+	// January in a plain file, February in a zip, and an updated 31st of January in a directory
+	writeTestFile(t, filepath.Join(dir, "january.csv"), nem12File(header, details,
+		updatedRecord300("20230130", "1", "A", "20230201000000", ""),
+		updatedRecord300("20230131", "1", "E", "20230201000000", ""),
+		"900",
+	))
+	writeTestZip(t, filepath.Join(dir, "february.zip"), map[string]string{
+		"b.csv": nem12File(header, details, record300("20230202", "1", "A"), "900"),
+		"a.csv": nem12File(header, details, record300("20230201", "1", "A"), "900"),
+	})
+	updates := filepath.Join(dir, "updates")
+	err := os.Mkdir(updates, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(updates, "update.csv"), nem12File(header, details,
+		updatedRecord300("20230131", "2", "A", "20230210000000", ""),
+		// The 3rd of February is missing
+		record300("20230204", "1", "A"),
+		"900",
+	))
+	writeTestFile(t, filepath.Join(updates, ".DS_Store"), "not a NEM12 file")
+
+	merger := NewMerger(testLogger(), Strict, nil)
+	data, err := merger.Merge(context.Background(),
+		filepath.Join(dir, "january.csv"), filepath.Join(dir, "february.zip"), updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readings := data["NMI1234567"][GeneralUsage]
+	if len(readings) != 5*24 {
+		t.Fatalf("got %v readings, want %v", len(readings), 5*24)
+	}
+	for i := 1; i < len(readings); i++ {
+		if !readings[i].StartTime.After(readings[i-1].StartTime) {
+			t.Fatalf("readings out of order at %v", readings[i].StartTime)
+		}
+	}
+	// The update for the 31st replaced the estimate
+	for _, reading := range readings {
+		if reading.StartTime.Day() == 31 && (reading.EnergyKWh != 4 || !reading.IsActual()) {
+			t.Errorf("got %v kWh at %v, want the updated 4 kWh", reading.EnergyKWh, reading.StartTime)
+		}
+	}
+
+	report := merger.Report()
+	if len(report.Sources) != 4 {
+		t.Errorf("got reports for %v sources, want 4", len(report.Sources))
+	}
+	if _, ok := report.Sources[filepath.Join(dir, "february.zip", "a.csv")]; !ok {
+		t.Errorf("no report for the file inside the zip")
+	}
+	if report.Merged.ReplacedIntervals != 1 {
+		t.Errorf("replaced %v intervals, want 1", report.Merged.ReplacedIntervals)
+	}
+	missing := time.Date(2023, 2, 3, 0, 0, 0, 0, time.UTC)
+	if len(report.Gaps) != 1 || !report.Gaps[0].From.Equal(missing) || report.Gaps[0].Days() != 1 {
+		t.Errorf("got gaps %+v, want just the 3rd of February", report.Gaps)
+	}
+	if merger.File().Header == nil || len(merger.File().Blocks) != 4 {
+		t.Errorf("merged records don't cover every file")
+	}
+}
+
+func TestMergeNamesFailingSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.csv")
+	writeTestFile(t, path, nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,",
+		"300,2023XX01,1",
+		"900",
+	))
+	_, err := NewMerger(testLogger(), Strict, nil).Merge(context.Background(), path)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !strings.HasPrefix(err.Error(), path) {
+		t.Errorf("got %v, want a ParseError naming %v", err, path)
+	}
+}
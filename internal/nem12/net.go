@@ -0,0 +1,184 @@
+package nem12
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The flow of energy to and from the grid for one interval. Import is energy drawn from the grid
+// and export is energy sent to it, both as positive values.
+type NetReading struct {
+	StartTime time.Time
+	EndTime   time.Time
+	// Energy imported as measured by the meter, before any netting. For a gross metered site this
+	// is the site's consumption.
+	ImportKWh float64
+	// Energy exported as measured by the meter, before any netting. For a gross metered site this
+	// is everything the site generated.
+	ExportKWh float64
+	// Whether consumption and generation are metered separately, rather than the flow at the grid
+	// connection being metered in each direction
+	Gross bool
+}
+
+// Import minus export. Positive when more energy was drawn from the grid than sent to it over the
+// interval, negative when more was sent.
+func (r NetReading) NetKWh() float64 {
+	return r.ImportKWh - r.ExportKWh
+}
+
+// The energy that would be billed as usage under net metering. For a gross metered site, import
+// and export within the interval cancel out. A net metered site's meter has already done that as
+// the energy flowed, so its import is billed as it is. Never negative.
+func (r NetReading) NetImportKWh() float64 {
+	if !r.Gross {
+		return r.ImportKWh
+	}
+	return max(r.NetKWh(), 0)
+}
+
+// The energy that would be credited as feed-in under net metering. Never negative.
+func (r NetReading) NetExportKWh() float64 {
+	if !r.Gross {
+		return r.ExportKWh
+	}
+	return max(-r.NetKWh(), 0)
+}
+
+// An import or export element in an NMI configuration, e.g. the E1 and B1 in E1E2B1
+var elementPattern = regexp.MustCompile(`([EB])(\d)`)
+
+// Works out whether an NMI configuration is gross metered. An import element and export element
+// with the same number (e.g. E1 and B1) are the two registers of one meter at the grid connection,
+// which is net metering. An export element without a matching import element (e.g. the B2 in E1B2)
+// is a separate generation meter, which is gross metering.
+func grossMetered(configuration string) bool {
+	imports := make(map[string]bool)
+	var exports []string
+	for _, match := range elementPattern.FindAllStringSubmatch(configuration, -1) {
+		if match[1] == "E" {
+			imports[match[2]] = true
+		} else {
+			exports = append(exports, match[2])
+		}
+	}
+	for _, element := range exports {
+		if !imports[element] {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines up the general usage and export of each NMI interval by interval, using the NMI
+// configuration to tell whether the site is gross or net metered. Intervals keep the length the
+// meter recorded them at, since netting over a longer period would let import in one interval
+// cancel out export in another. If an NMI's channels have different interval lengths, they're
+// lined up at the longest one. Intervals that only have one of the two are treated as having no
+// flow in the other direction. Controlled load isn't included because it's metered and billed
+// separately.
+//
+// Every interval in the file is used, so call Reconcile first if the same day might appear more
+// than once. If suffixes is nil, DefaultSuffixMapping is used.
+func (f *File) NetSeries(suffixes *SuffixMapping) (map[NMI][]NetReading, error) {
+	if suffixes == nil {
+		suffixes = DefaultSuffixMapping()
+	}
+	type channelBlock struct {
+		*NMIDataBlock
+		readingType ReadingType
+	}
+	blocksByNMI := make(map[NMI][]channelBlock)
+	gross := make(map[NMI]bool)
+	for _, block := range f.Blocks {
+		readingType, ok := suffixes.ReadingType(block.Details.NMISuffix)
+		if !ok || (readingType != GeneralUsage && readingType != Export) {
+			continue
+		}
+		nmi := NMI(block.Details.NMI)
+		blocksByNMI[nmi] = append(blocksByNMI[nmi], channelBlock{block, readingType})
+		if block.Details.NMIConfiguration != "" {
+			gross[nmi] = grossMetered(block.Details.NMIConfiguration)
+		}
+	}
+
+	series := make(map[NMI][]NetReading)
+	for nmi, blocks := range blocksByNMI {
+		length := 0
+		for _, block := range blocks {
+			length = max(length, block.Details.IntervalLength)
+		}
+		intervalLength := time.Duration(length) * time.Minute
+		intervals := make(map[int64]*NetReading)
+		for _, block := range blocks {
+			err := block.eachInterval(func(start time.Time, _ time.Duration, kWh float64) {
+				// Interval dates are midnight UTC and every interval length divides a day, so this
+				// lines the interval up with the longer one it falls in
+				start = start.Truncate(intervalLength)
+				reading := intervals[start.Unix()]
+				if reading == nil {
+					reading = &NetReading{StartTime: start, EndTime: start.Add(intervalLength), Gross: gross[nmi]}
+					intervals[start.Unix()] = reading
+				}
+				if block.readingType == GeneralUsage {
+					reading.ImportKWh = reading.ImportKWh + kWh
+				} else {
+					reading.ExportKWh = reading.ExportKWh + kWh
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(intervals) == 0 {
+			continue
+		}
+		readings := make([]NetReading, 0, len(intervals))
+		for _, reading := range intervals {
+			readings = append(readings, *reading)
+		}
+		sort.Slice(readings, func(i, j int) bool { return readings[i].StartTime.Before(readings[j].StartTime) })
+		series[nmi] = readings
+	}
+	return series, nil
+}
+
+// Writes net series as CSV with a header row, sorted by NMI and then by time. Times are in RFC 3339
+// format. Alongside what the meter measured each way, each row has what would be billed as usage
+// and credited as feed-in under net metering, so the series can be checked against a bill.
+func WriteNetSeries(w io.Writer, series map[NMI][]NetReading) error {
+	nmis := make([]NMI, 0, len(series))
+	for nmi := range series {
+		nmis = append(nmis, nmi)
+	}
+	sort.Slice(nmis, func(i, j int) bool { return nmis[i] < nmis[j] })
+
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"nmi", "startTime", "endTime", "importKWh", "exportKWh", "netImportKWh", "netExportKWh", "gross"})
+	if err != nil {
+		return err
+	}
+	for _, nmi := range nmis {
+		for _, reading := range series[nmi] {
+			err = writer.Write([]string{
+				string(nmi),
+				reading.StartTime.Format(time.RFC3339),
+				reading.EndTime.Format(time.RFC3339),
+				strconv.FormatFloat(reading.ImportKWh, 'f', -1, 64),
+				strconv.FormatFloat(reading.ExportKWh, 'f', -1, 64),
+				strconv.FormatFloat(reading.NetImportKWh(), 'f', -1, 64),
+				strconv.FormatFloat(reading.NetExportKWh(), 'f', -1, 64),
+				strconv.FormatBool(reading.Gross),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
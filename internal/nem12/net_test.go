@@ -0,0 +1,140 @@
+package nem12
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds an actual 300 record for a day of intervals, taking each interval's value from fn
+func intervalRecord(date string, intervals int, fn func(i int) float64) string {
+	values := make([]string, intervals)
+	for i := range values {
+		values[i] = strconv.FormatFloat(fn(i), 'f', -1, 64)
+	}
+	return "300," + date + "," + strings.Join(values, ",") + ",A,,,,"
+}
+
+func netSeriesFrom(t *testing.T, lines ...string) []NetReading {
+	t.Helper()
+	content := nem12File(append([]string{"100,NEM12,200301011534,MDP1,Retailer1"}, append(lines, "900")...)...)
+	parser := NewParser(testLogger(), strings.NewReader(content), Strict, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := file.NetSeries(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return series["NMI1234567"]
+}
+
+func TestNetSeriesNetMetered(t *testing.T) {
+	// One meter measuring the flow each way at the grid connection. The first half hour only
+	// imports, the second only exports, and the third does both.
+	readings := netSeriesFrom(t,
+		"200,NMI1234567,E1B1,1,E1,N1,METER1,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return []float64{1, 0, 0.2}[min(i, 2)] }),
+		"200,NMI1234567,E1B1,1,B1,N1,METER1,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return []float64{0, 1, 0.5}[min(i, 2)] }),
+	)
+	if len(readings) != 48 {
+		t.Fatalf("got %v readings, want one for each half hour", len(readings))
+	}
+	if !readings[1].StartTime.Equal(time.Date(2023, 1, 1, 0, 30, 0, 0, time.UTC)) || readings[1].EndTime.Sub(readings[1].StartTime) != 30*time.Minute {
+		t.Errorf("got a reading from %v to %v", readings[1].StartTime, readings[1].EndTime)
+	}
+	// Summed into an hour, the first two half hours would have cancelled out
+	if readings[0].NetImportKWh() != 1 || readings[1].NetExportKWh() != 1 {
+		t.Errorf("got %v imported and %v exported", readings[0].NetImportKWh(), readings[1].NetExportKWh())
+	}
+	// The meter has already netted the flows, so both directions are billed as they are
+	if readings[2].Gross || readings[2].NetImportKWh() != 0.2 || readings[2].NetExportKWh() != 0.5 {
+		t.Errorf("got %+v netted to %v imported and %v exported", readings[2], readings[2].NetImportKWh(), readings[2].NetExportKWh())
+	}
+}
+
+func TestNetSeriesGrossMetered(t *testing.T) {
+	// Consumption on the main meter and generation on a separate meter
+	readings := netSeriesFrom(t,
+		"200,NMI1234567,E1B2,1,E1,N1,METER1,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return 1 }),
+		"200,NMI1234567,E1B2,2,B2,N1,METER2,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return []float64{1.5, 0.25}[min(i, 1)] }),
+	)
+	if len(readings) != 48 {
+		t.Fatalf("got %v readings, want one for each half hour", len(readings))
+	}
+	if !readings[0].Gross {
+		t.Errorf("E1B2 wasn't treated as gross metered")
+	}
+	if readings[0].NetImportKWh() != 0 || readings[0].NetExportKWh() != 0.5 {
+		t.Errorf("got %v imported and %v exported, want 0 and 0.5", readings[0].NetImportKWh(), readings[0].NetExportKWh())
+	}
+	if readings[1].NetImportKWh() != 0.75 || readings[1].NetExportKWh() != 0 {
+		t.Errorf("got %v imported and %v exported, want 0.75 and 0", readings[1].NetImportKWh(), readings[1].NetExportKWh())
+	}
+}
+
+func TestNetSeriesMixedIntervalLengths(t *testing.T) {
+	readings := netSeriesFrom(t,
+		"200,NMI1234567,E1B1,1,E1,N1,METER1,Wh,5,",
+		intervalRecord("20230101", 288, func(i int) float64 { return 100 }),
+		"200,NMI1234567,E1B1,1,B1,N1,METER1,kWh,30,",
+		intervalRecord("20230101", 48, func(i int) float64 { return 0.1 }),
+	)
+	if len(readings) != 48 {
+		t.Fatalf("got %v readings, want them lined up at 30 minutes", len(readings))
+	}
+	for _, reading := range readings {
+		if !closeEnough(reading.ImportKWh, 0.6) || reading.ExportKWh != 0.1 {
+			t.Fatalf("got %+v, want 0.6 kWh imported and 0.1 exported", reading)
+		}
+	}
+}
+
+func closeEnough(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func TestGrossMetered(t *testing.T) {
+	for configuration, want := range map[string]bool{
+		"E1B1":   false,
+		"E1E2B1": false,
+		"E1B2":   true,
+		"E1":     false,
+		"":       false,
+	} {
+		if got := grossMetered(configuration); got != want {
+			t.Errorf("got %v for %q, want %v", got, configuration, want)
+		}
+	}
+}
+
+func TestWriteNetSeries(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := map[NMI][]NetReading{
+		"NMI7654321": {
+			{StartTime: start, EndTime: start.Add(30 * time.Minute), ImportKWh: 1, ExportKWh: 1.5, Gross: true},
+		},
+		"NMI1234567": {
+			{StartTime: start, EndTime: start.Add(30 * time.Minute), ImportKWh: 0.2, ExportKWh: 0.5},
+			{StartTime: start.Add(30 * time.Minute), EndTime: start.Add(time.Hour), ImportKWh: 1.25},
+		},
+	}
+	var written strings.Builder
+	err := WriteNetSeries(&written, series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "nmi,startTime,endTime,importKWh,exportKWh,netImportKWh,netExportKWh,gross\n" +
+		"NMI1234567,2023-01-01T00:00:00Z,2023-01-01T00:30:00Z,0.2,0.5,0.2,0.5,false\n" +
+		"NMI1234567,2023-01-01T00:30:00Z,2023-01-01T01:00:00Z,1.25,0,1.25,0,false\n" +
+		"NMI7654321,2023-01-01T00:00:00Z,2023-01-01T00:30:00Z,1,1.5,0,0.5,true\n"
+	if written.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", written.String(), want)
+	}
+}
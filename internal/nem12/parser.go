@@ -1,38 +1,178 @@
 package nem12
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
 )
 
 type Parser struct {
-	logger *slog.Logger
-	file   *os.File
+	logger   *slog.Logger
+	file     io.ReadSeeker
+	mode     ParseMode
+	suffixes *SuffixMapping
+	parsed   *File
+	report   *ParseReport
 }
 
-func NewParser(logger *slog.Logger, file *os.File) *Parser {
+// Determines how the parser handles files that don't follow the NEM12 specification
+type ParseMode int
+
+const (
+	// Drops the affected block or interval when a record is malformed and carries on, recording
+	// everything dropped in the report
+	Lenient ParseMode = iota
+	// Fails on any violation of the specification. Useful for validating files from metering providers.
+	Strict
+)
+
+// A single record read from the file, along with where it came from
+type rawRecord struct {
+	fields []string
+	// 1-indexed line number of the record in the file
+	line int
+	// The parsed record indicator, or 0 if it hasn't been parsed yet
+	indicator int
+	// Set if the record couldn't be read at all, in which case the other fields are empty
+	err error
+}
+
+// If suffixes is nil, DefaultSuffixMapping is used
+func NewParser(logger *slog.Logger, file io.ReadSeeker, mode ParseMode, suffixes *SuffixMapping) *Parser {
+	if suffixes == nil {
+		suffixes = DefaultSuffixMapping()
+	}
 	return &Parser{
-		logger: logger,
-		file:   file,
+		logger:   logger,
+		file:     file,
+		mode:     mode,
+		suffixes: suffixes,
+		report:   &ParseReport{},
 	}
 }
 
-func (p *Parser) Parse() (UsageData, error) {
+// Parses the NEM12 file into hourly readings. Where the same day appears more than once for an NMI
+// and suffix, only the latest version is used, so every day is held in memory until the file has
+// been read. Each one is released as its readings are added to the usage data. For files too big to
+// hold at once, use Stream.
+func (p *Parser) Parse(ctx context.Context) (UsageData, error) {
+	builder := newUsageBuilder()
+	err := p.Stream(ctx, builder.add)
+	if err != nil {
+		return nil, err
+	}
+	p.report.ReplacedIntervals = builder.replaced
+	data := builder.build()
+	p.report.Quality = data.QualitySummary()
+	return data, nil
+}
+
+// Returns the report for the most recent call to Parse, Stream or ParseFile
+func (p *Parser) Report() *ParseReport {
+	return p.report
+}
+
+// Returns the records from the most recent call to ParseFile. This includes details that don't
+// make it into the usage data, like the header, each block's next scheduled read date and the B2B
+// details (500 records) with their index reads. Parse and Stream don't hold onto records, so after
+// those only the header is available. To get the rest, use ParseFile (and ToUsageData if the
+// readings are needed too) or Merger, whose File method has everything.
+func (p *Parser) File() *File {
+	return p.parsed
+}
+
+// Parses the NEM12 file one NMI, suffix and day at a time, calling fn with the readings from each
+// 300 record as soon as it's complete. The parser doesn't hold onto days once fn returns, so files
+// of any size can be parsed in bounded memory as long as fn doesn't keep them either. This only
+// covers parsing: the calculator costs UsageData, which holds every reading, so costing a file still
+// needs all of it in memory. Days are passed on as they're found, so if the same day appears more
+// than once it's up to fn to decide which version to use. If fn returns an error, parsing stops and
+// the error is returned.
+func (p *Parser) Stream(ctx context.Context, fn func(day *DayReadings) error) error {
+	p.report = &ParseReport{}
+	var readingType ReadingType
+	supported := false
+	header, err := p.parseRecords(ctx, recordHandler{
+		block: func(block *NMIDataBlock) error {
+			readingType, supported = p.readingType(block)
+			return nil
+		},
+		interval: func(block *NMIDataBlock, interval *IntervalDataRecord) error {
+			if !supported {
+				return nil
+			}
+			day, err := p.dayReadings(block, readingType, interval)
+			if err != nil {
+				return err
+			}
+			return fn(day)
+		},
+	})
+	p.parsed = &File{Header: header}
+	return err
+}
+
+// Parses the NEM12 file into its records without converting them into hourly readings. Blocks with
+// unsupported suffixes are kept as-is so the file can be written back out without losing anything.
+func (p *Parser) ParseFile(ctx context.Context) (*File, error) {
+	p.report = &ParseReport{}
+	file := &File{}
+	header, err := p.parseRecords(ctx, recordHandler{
+		block: func(block *NMIDataBlock) error {
+			file.Blocks = append(file.Blocks, block)
+			return nil
+		},
+		interval: func(block *NMIDataBlock, interval *IntervalDataRecord) error {
+			block.Intervals = append(block.Intervals, interval)
+			return nil
+		},
+		b2b: func(block *NMIDataBlock, b2b *B2bDetailsRecord) error {
+			b2b.placed = true
+			if len(block.Intervals) > 0 {
+				b2b.after = block.Intervals[len(block.Intervals)-1]
+			}
+			block.B2bDetails = append(block.B2bDetails, b2b)
+			return nil
+		},
+	})
+	if err != nil {
+		p.parsed = nil
+		return nil, err
+	}
+	file.Header = header
+	p.parsed = file
+	return file, nil
+}
+
+// Callbacks for each record as the file is parsed. Every callback is optional. If one returns an
+// error, parsing stops.
+type recordHandler struct {
+	// Called with each new block as soon as its 200 record is parsed
+	block func(block *NMIDataBlock) error
+	// Called with each 300 record once all of its 400 records have been applied
+	interval func(block *NMIDataBlock, interval *IntervalDataRecord) error
+	b2b      func(block *NMIDataBlock, b2b *B2bDetailsRecord) error
+}
+
+// Reads through the file a record at a time, handing each record to the handler once it's complete.
+// Records aren't kept once they've been handed over. Returns the header, which is nil if the file
+// doesn't have one.
+func (p *Parser) parseRecords(ctx context.Context, handler recordHandler) (*HeaderRecord, error) {
 	nemReader := p.createNemReader(p.file)
-	hasHeader, err := p.checkNem12Header(nemReader)
+	header, err := p.parseHeader(nemReader)
 	if err != nil {
 		return nil, err
 	}
-	if !hasHeader {
+	if header == nil {
 		// Reset the file and recreate the reader to start reading from the first row again
 		_, err = p.file.Seek(0, io.SeekStart)
 		if err != nil {
@@ -43,96 +183,340 @@ func (p *Parser) Parse() (UsageData, error) {
 		nemReader = p.createNemReader(p.file)
 	}
 
-	data := make(UsageData)
-	// Keep track of the current 200 record because it specifies the rules for the subsequent 300 blocks
-	var current200 *NMIDataDetailsRecord
-	// Keep track of the current 300 record because it needs to be adjusted by any subsequent 400 blocks
+	// Keep track of the current 200 block because it specifies the rules for the subsequent 300 records
+	var currentBlock *NMIDataBlock
+	// Keep track of the current 300 record because it needs to be adjusted by any subsequent 400 records
 	var current300 *IntervalDataRecord
-	// We can only handle certain kinds of 200 blocks (see IsValidReadingType). If we detect an
-	// unsupported block, we skip it.
-	skipping200 := false
-
-	// Converts the current 300 interval record to an hourly reading. You would call this after you've
-	// applied all the 400 records to the interval and are ready to finalise it.
-	finalise300 := func() {
-		if current300 != nil {
-			readings := p.nem12IntervalToHourlyReadings(current200, current300)
-			data[NMI(current200.NMI)][ReadingType(current200.NMISuffix)] =
-				append(data[NMI(current200.NMI)][ReadingType(current200.NMISuffix)], readings...)
-			current300 = nil
+	var current300Record *rawRecord
+	// In lenient mode, we drop a block if its 200 record is bad and an interval if its 300 record or
+	// any of its 400 records are bad. The records that follow are then skipped until we're back to
+	// something we can use.
+	skippingBlock := false
+	skippingInterval := false
+	lastLine := 0
+
+	// Checks the 400 records applied to the current 300 record agree with its quality method, then
+	// hands it over. You would call this once there can't be any more 400 records for it.
+	finalise300 := func() error {
+		if current300 == nil {
+			return nil
+		}
+		interval := current300
+		current300 = nil
+		if interval.QualityMethod == "V" {
+			for _, val := range interval.IntervalValues {
+				if val.Quality == nil {
+					err := p.violation(current300Record.errorAt(wholeRecord,
+						errors.New("quality method is V but 400 records don't cover every interval")))
+					if err != nil {
+						return err
+					}
+					break
+				}
+			}
 		}
+		if handler.interval == nil {
+			return nil
+		}
+		return handler.interval(currentBlock, interval)
+	}
+	dropInterval := func(err error) {
+		p.logger.Warn(err.Error())
+		p.report.DroppedIntervals = append(p.report.DroppedIntervals, DroppedInterval{
+			Line:   current300Record.line,
+			NMI:    currentBlock.Details.NMI,
+			Suffix: currentBlock.Details.NMISuffix,
+			Err:    err,
+		})
+		current300 = nil
+		skippingInterval = true
 	}
 
-	records := make(chan []string)
-	go p.getNem12Records(nemReader, records)
+	// Records are read on a separate goroutine so the CSV decoding can run ahead of us. When we
+	// return, for whatever reason, we cancel the reader and wait for it to finish so it can never be
+	// left blocked trying to send us a record.
+	readerCtx, cancelReader := context.WithCancel(ctx)
+	var readerDone sync.WaitGroup
+	defer readerDone.Wait()
+	defer cancelReader()
+	records := make(chan *rawRecord)
+	readerDone.Add(1)
+	go func() {
+		defer readerDone.Done()
+		p.getNem12Records(readerCtx, nemReader, records)
+	}()
 
 	for record := range records {
-		if len(record) == 0 {
+		if record.err != nil {
+			var parseErr *ParseError
+			if !errors.As(record.err, &parseErr) {
+				return nil, record.err
+			}
+			// We can't tell what kind of record it was, so any 400 records that follow could belong
+			// to it rather than to the current 300 record. Skip them until the next 300 record.
+			err = finalise300()
+			if err != nil {
+				return nil, err
+			}
+			err = p.violation(parseErr)
+			if err != nil {
+				return nil, err
+			}
+			skippingInterval = true
+			continue
+		}
+		if len(record.fields) == 0 {
 			continue
 		}
-		recordIndicator, err := strconv.Atoi(record[0])
+		lastLine = record.line
+		record.indicator, err = strconv.Atoi(record.fields[0])
 		if err != nil {
-			p.logger.Warn("Could not parse record indicator - moving to next line")
+			err = p.violation(record.errorAt(0, errors.New("record indicator cannot be parsed")))
+			if err != nil {
+				return nil, err
+			}
 			continue
 		}
-		switch recordIndicator {
-		case 200: // Data details
-			// We've come back around to a 200 record so if we were skipping the previous block, we're done
-			skipping200 = false
-			finalise300()
-			current200, err = p.parse200Record(record)
+		if record.indicator != 400 {
+			err = finalise300()
 			if err != nil {
 				return nil, err
 			}
-			if !IsValidReadingType(current200.NMISuffix) {
-				p.logger.Error(fmt.Sprintf("200 record has unsupported suffix %v. Skipping block.", current200.NMISuffix))
-				skipping200 = true
+		}
+		switch record.indicator {
+		case 200: // Data details
+			current300 = nil
+			skippingInterval = false
+			details, err := p.parse200Record(record)
+			if err != nil {
+				if p.mode == Strict {
+					return nil, err
+				}
+				p.logger.Warn(err.Error())
+				p.report.SkippedBlocks = append(p.report.SkippedBlocks, SkippedBlock{
+					Line:   record.line,
+					NMI:    record.field(1),
+					Suffix: record.field(4),
+					Reason: err.Error(),
+				})
+				currentBlock = nil
+				skippingBlock = true
 				continue
 			}
-			if data[NMI(current200.NMI)] == nil {
-				data[NMI(current200.NMI)] = make(map[ReadingType][]HourlyReading)
-			}
-			if data[NMI(current200.NMI)][ReadingType(current200.NMISuffix)] == nil {
-				data[NMI(current200.NMI)][ReadingType(current200.NMISuffix)] = make([]HourlyReading, 0)
+			skippingBlock = false
+			currentBlock = &NMIDataBlock{Details: *details, line: record.line}
+			p.logger.Debug("Parsed 200 record", slog.Any("record", details))
+			if handler.block != nil {
+				err = handler.block(currentBlock)
+				if err != nil {
+					return nil, err
+				}
 			}
-			p.logger.Debug("Parsed 200 record", slog.Any("record", current200))
 		case 300: // Interval data
-			if skipping200 {
+			if skippingBlock {
 				continue
 			}
-			finalise300()
-			current300, err = p.parse300Record(record, current200)
+			if currentBlock == nil {
+				err = p.violation(record.errorAt(wholeRecord, errors.New("300 record found before any 200 record")))
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			skippingInterval = false
+			current300 = nil
+			current300Record = record
+			interval, err := p.parse300Record(record, &currentBlock.Details)
 			if err != nil {
-				return nil, err
+				if p.mode == Strict {
+					return nil, err
+				}
+				dropInterval(err)
+				continue
 			}
+			current300 = interval
 			p.logger.Debug("Parsed 300 record", slog.Any("record", current300))
 		case 400: // Interval event
-			if skipping200 {
+			if skippingBlock || skippingInterval {
 				continue
 			}
-			event, err := p.parse400Record(record, current200)
+			if current300 == nil {
+				err = p.violation(record.errorAt(wholeRecord, errors.New("400 record found before any 300 record")))
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if current300.QualityMethod != "V" {
+				err = p.violation(record.errorAt(wholeRecord, errors.New("400 record follows a 300 record whose quality method isn't V")))
+				if err != nil {
+					return nil, err
+				}
+			}
+			event, err := p.parse400Record(record, current300)
 			if err != nil {
-				return nil, err
+				if p.mode == Strict {
+					return nil, err
+				}
+				dropInterval(err)
+				continue
 			}
 			p.adjustInterval(current300, event)
 			p.logger.Debug("Parsed 400 record", slog.Any("record", event))
 		case 500: // B2B details
 			// This is a manual reading that provides the total recorded accumulated energy for a
-			// Datastream retrieved from a meter’s register at the time of collection. It doesn't
-			// really serve our purposes so we ignore it.
-			continue
+			// Datastream retrieved from a meter’s register at the time of collection
+			if skippingBlock {
+				continue
+			}
+			if currentBlock == nil {
+				err = p.violation(record.errorAt(wholeRecord, errors.New("500 record found before any 200 record")))
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			b2b, b2bErr := p.parse500Record(record)
+			if b2bErr != nil {
+				// Losing a B2B record doesn't affect any readings so there's nothing else to drop
+				err = p.violation(b2bErr)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			p.logger.Debug("Parsed 500 record", slog.Any("record", b2b))
+			if handler.b2b != nil {
+				err = handler.b2b(currentBlock, b2b)
+				if err != nil {
+					return nil, err
+				}
+			}
 		case 900: // End of data
-			finalise300()
-			return data, nil
+			// Nothing should come after the 900 record, but we only need to look at the next record to
+			// know whether anything does
+			for trailing := range records {
+				if trailing.err == nil && len(trailing.fields) == 0 {
+					continue
+				}
+				line := trailing.line
+				var parseErr *ParseError
+				if errors.As(trailing.err, &parseErr) {
+					line = parseErr.Line
+				}
+				err = p.violation(&ParseError{Line: line, Field: wholeRecord, Err: errors.New("record found after the 900 record")})
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+			return header, nil
 		default:
-			p.logger.Warn(fmt.Sprintf("Unrecognised record indicator: %v", recordIndicator))
+			err = p.violation(record.errorAt(0, errors.New("unrecognised record indicator")))
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
-	p.logger.Warn("Missing 900 record")
-	return data, nil
+	// The reader stops early if the context is cancelled, which isn't the same as the file ending
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	err = finalise300()
+	if err != nil {
+		return nil, err
+	}
+	p.report.Missing900 = true
+	err = p.violation(&ParseError{Line: lastLine, Field: wholeRecord, Err: errors.New("missing 900 record")})
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Works out the reading type for a block from its suffix. If the suffix isn't in the suffix mapping,
+// the block is recorded as skipped and false is returned.
+func (p *Parser) readingType(block *NMIDataBlock) (ReadingType, bool) {
+	readingType, ok := p.suffixes.ReadingType(block.Details.NMISuffix)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("200 record has unsupported suffix %v. Skipping block.", block.Details.NMISuffix))
+		p.report.addUnsupportedSuffix(block.Details.NMISuffix)
+		p.report.SkippedBlocks = append(p.report.SkippedBlocks, SkippedBlock{
+			Line:   block.line,
+			NMI:    block.Details.NMI,
+			Suffix: block.Details.NMISuffix,
+			Reason: "unsupported suffix",
+		})
+	}
+	return readingType, ok
 }
 
-func (p *Parser) createNemReader(file *os.File) *csv.Reader {
+func (p *Parser) dayReadings(block *NMIDataBlock, readingType ReadingType, interval *IntervalDataRecord) (*DayReadings, error) {
+	readings, err := p.nem12IntervalToHourlyReadings(&block.Details, interval)
+	if err != nil {
+		return nil, err
+	}
+	return &DayReadings{
+		NMI:               NMI(block.Details.NMI),
+		Suffix:            block.Details.NMISuffix,
+		ReadingType:       readingType,
+		Date:              interval.IntervalDate,
+		Readings:          readings,
+		UpdateDateTime:    interval.UpdateDateTime,
+		MSATSLoadDateTime: interval.MSATSLoadDateTime,
+	}, nil
+}
+
+// Converts the records of a parsed file into hourly readings, in the same way as Parse. Use this
+// with ParseFile to get both the readings and the details that only the records have, like B2B
+// details, from a single pass over the file. Blocks with suffixes that aren't in the suffix mapping
+// are skipped.
+func (p *Parser) ToUsageData(file *File) UsageData {
+	builder := newUsageBuilder()
+	for _, block := range file.Blocks {
+		readingType, ok := p.readingType(block)
+		if !ok {
+			continue
+		}
+		// Blocks from ParseFile have already had their unit checked, but blocks put together by hand
+		// may not have
+		_, err := convertEnergy(0, block.Details.UOM)
+		if err != nil {
+			p.logger.Error(err.Error())
+			p.report.SkippedBlocks = append(p.report.SkippedBlocks, SkippedBlock{
+				Line:   block.line,
+				NMI:    block.Details.NMI,
+				Suffix: block.Details.NMISuffix,
+				Reason: err.Error(),
+			})
+			continue
+		}
+		for _, interval := range block.Intervals {
+			// Can't fail because the unit has been checked and add never returns an error
+			day, _ := p.dayReadings(block, readingType, interval)
+			_ = builder.add(day)
+		}
+	}
+	p.report.ReplacedIntervals = p.report.ReplacedIntervals + builder.replaced
+	data := builder.build()
+	p.report.Quality = data.QualitySummary()
+	return data
+}
+
+// Handles a violation of the NEM12 specification that we're able to work around. In strict mode
+// it's returned as an error. Otherwise it's logged and recorded in the report as a warning, and nil
+// is returned so parsing can continue.
+func (p *Parser) violation(err *ParseError) error {
+	if p.mode == Strict {
+		return err
+	}
+	p.logger.Warn(err.Error())
+	p.report.Warnings = append(p.report.Warnings, err)
+	return nil
+}
+
+func (p *Parser) createNemReader(file io.Reader) *csv.Reader {
 	csvReader := csv.NewReader(file)
 	// NEM12 files have variable fields per record, so we tell the CSV reader to not expect
 	// any particular number
@@ -140,122 +524,219 @@ func (p *Parser) createNemReader(file *os.File) *csv.Reader {
 	return csvReader
 }
 
-func (p *Parser) checkNem12Header(nemReader *csv.Reader) (bool, error) {
-	record, err := nemReader.Read()
+// Reads the 100 header record if there is one. If the first record isn't a header, nil is returned.
+func (p *Parser) parseHeader(nemReader *csv.Reader) (*HeaderRecord, error) {
+	fields, err := nemReader.Read()
 	if err != nil {
-		return false, err
+		return nil, readError(err)
 	}
-	if record[0] != "100" {
+	record := &rawRecord{fields: fields, line: 1}
+	if record.field(0) != "100" {
 		p.logger.Debug("No header record - assuming NEM12 format")
-		return false, nil
+		if p.mode == Strict {
+			return nil, record.errorAt(0, errors.New("missing 100 header record"))
+		}
+		return nil, nil
 	}
-	if record[1] != "NEM12" {
-		return true, errors.New("header record indicates this is not a NEM12 file")
+	record.indicator = 100
+	if record.field(1) != "NEM12" {
+		return nil, record.errorAt(1, errors.New("header record indicates this is not a NEM12 file"))
+	}
+	dateTime, err := parseOptionalTime(record.field(2), dateTime12Format)
+	if err != nil {
+		// The file creation time is only informational so it's not worth failing over in lenient mode
+		err = p.violation(record.errorAt(2, errors.New("date time cannot be parsed")))
+		if err != nil {
+			return nil, err
+		}
 	}
-	return true, nil
+	return &HeaderRecord{
+		VersionHeader:   record.field(1),
+		DateTime:        dateTime,
+		FromParticipant: record.field(3),
+		ToParticipant:   record.field(4),
+	}, nil
 }
 
-func (p *Parser) getNem12Records(csvReader *csv.Reader, records chan<- []string) {
+// Sends each record from the reader down the channel until the end of the file, a read error, or the
+// context is cancelled. Read errors are sent as records. A malformed record doesn't stop the reader,
+// since the CSV reader can carry on from the next one, but any other read error does. The channel
+// is closed when it's done.
+func (p *Parser) getNem12Records(ctx context.Context, csvReader *csv.Reader, records chan<- *rawRecord) {
+	defer close(records)
 	for {
-		record, err := csvReader.Read()
+		var record *rawRecord
+		fields, err := csvReader.Read()
 		if err == io.EOF {
-			break
+			return
 		}
 		if err != nil {
-			p.logger.Error(err.Error())
+			record = &rawRecord{err: readError(err)}
+		} else {
+			line, _ := csvReader.FieldPos(0)
+			record = &rawRecord{fields: fields, line: line}
+		}
+		select {
+		case records <- record:
+		case <-ctx.Done():
+			return
+		}
+		var csvErr *csv.ParseError
+		if err != nil && !errors.As(err, &csvErr) {
+			return
 		}
-		records <- record
 	}
-	close(records)
 }
 
-func (p *Parser) parse200Record(record []string) (*NMIDataDetailsRecord, error) {
+func (p *Parser) parse200Record(record *rawRecord) (*NMIDataDetailsRecord, error) {
 	// Mandatory field
-	intervalLength, err := strconv.Atoi(record[8])
+	intervalLengthStr, err := record.requiredField(8)
 	if err != nil {
-		return nil, errors.New("interval length cannot be parsed")
+		return nil, err
+	}
+	intervalLength, err := strconv.Atoi(intervalLengthStr)
+	if err != nil {
+		return nil, record.errorAt(8, errors.New("interval length cannot be parsed"))
+	}
+	// The interval length must evenly divide both an hour and a day for us to make sense of it
+	if intervalLength <= 0 || 60%intervalLength != 0 {
+		return nil, record.errorAt(8, errors.New("unsupported interval length"))
+	}
+	// Every reading in the block is converted with the unit, so a block we can't convert is no use
+	_, err = convertEnergy(0, record.field(7))
+	if err != nil {
+		return nil, record.errorAt(7, err)
+	}
+	nextScheduledReadDate, err := parseOptionalTime(record.field(9), dateFormat)
+	if err != nil {
+		// The next scheduled read date is only informational so, like the header's date time, it's
+		// not worth dropping the block's readings over in lenient mode
+		err = p.violation(record.errorAt(9, errors.New("next scheduled read date cannot be parsed")))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &NMIDataDetailsRecord{
-		NMI:                     record[1],
-		NMIConfiguration:        record[2],
-		RegisterID:              record[3],
-		NMISuffix:               record[4],
-		MDMDataStreamIdentifier: record[5],
-		MeterSerialNumber:       record[6],
-		UOM:                     record[7],
+		NMI:                     record.field(1),
+		NMIConfiguration:        record.field(2),
+		RegisterID:              record.field(3),
+		NMISuffix:               record.field(4),
+		MDMDataStreamIdentifier: record.field(5),
+		MeterSerialNumber:       record.field(6),
+		UOM:                     record.field(7),
 		IntervalLength:          intervalLength,
-		// We don't care about NextScheduledReadDate
+		NextScheduledReadDate:   nextScheduledReadDate,
 	}, nil
 }
 
-func (p *Parser) parse300Record(record []string, currentDetails *NMIDataDetailsRecord) (*IntervalDataRecord, error) {
+func (p *Parser) parse300Record(record *rawRecord, currentDetails *NMIDataDetailsRecord) (*IntervalDataRecord, error) {
 	// Mandatory field
-	intervalDate, err := time.Parse("20060102", record[1])
+	intervalDate, err := time.Parse(dateFormat, record.field(1))
 	if err != nil {
-		return nil, errors.New("interval date cannot be parsed")
+		return nil, record.errorAt(1, errors.New("interval date cannot be parsed"))
 	}
 
 	// As per the spec, "The number of values provided must equal 1440 divided by the IntervalLength"
 	// There are 2 other mandatory fields,
 	numIntervalVals := 1440 / currentDetails.IntervalLength
 	idxAfterIntervalVals := numIntervalVals + 2
-	if len(record) < idxAfterIntervalVals {
-		return nil, errors.New("not enough interval values")
+	if len(record.fields) < idxAfterIntervalVals {
+		return nil, record.errorAt(wholeRecord, errors.New("not enough interval values"))
 	}
 
 	vals := []IntervalValue{}
-	for _, val := range record[2 : numIntervalVals+2] {
-		valFlt, err := strconv.ParseFloat(val, 64)
+	for i := 2; i < idxAfterIntervalVals; i++ {
+		valFlt, err := strconv.ParseFloat(record.fields[i], 64)
 		if err != nil {
-			return nil, errors.New("interval value cannot be parsed")
+			return nil, record.errorAt(i, errors.New("interval value cannot be parsed"))
 		}
 		vals = append(vals, IntervalValue{Value: valFlt})
 	}
 
+	qualityMethod := record.field(idxAfterIntervalVals)
+	if qualityMethod == "" {
+		// Without it we can't tell actual reads from estimates, so the readings count as estimates
+		err = p.violation(record.errorAt(idxAfterIntervalVals, errors.New("quality method is missing")))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var reasonCode *int
-	if record[idxAfterIntervalVals+1] != "" {
-		reasonCodeInt, err := strconv.Atoi(record[idxAfterIntervalVals+1])
+	if record.field(idxAfterIntervalVals+1) != "" {
+		reasonCodeInt, err := strconv.Atoi(record.field(idxAfterIntervalVals + 1))
 		if err != nil {
-			return nil, errors.New("reason code cannot be parsed")
+			return nil, record.errorAt(idxAfterIntervalVals+1, errors.New("reason code cannot be parsed"))
 		}
 		reasonCode = &reasonCodeInt
 	}
 
+	// These tell us which version of the data is the latest when the same day appears more than once
+	updateDateTime, err := parseOptionalTime(record.field(idxAfterIntervalVals+3), dateTime14Format)
+	if err != nil {
+		return nil, record.errorAt(idxAfterIntervalVals+3, errors.New("update date time cannot be parsed"))
+	}
+	msatsLoadDateTime, err := parseOptionalTime(record.field(idxAfterIntervalVals+4), dateTime14Format)
+	if err != nil {
+		return nil, record.errorAt(idxAfterIntervalVals+4, errors.New("MSATS load date time cannot be parsed"))
+	}
+
 	return &IntervalDataRecord{
 		IntervalDate:      intervalDate,
 		IntervalValues:    vals,
-		QualityMethod:     record[idxAfterIntervalVals],
+		QualityMethod:     qualityMethod,
 		ReasonCode:        reasonCode,
-		ReasonDescription: record[idxAfterIntervalVals+2],
-		// We don't care about UpdateDateTime and MSATSLoadDateTime
+		ReasonDescription: record.field(idxAfterIntervalVals + 2),
+		UpdateDateTime:    updateDateTime,
+		MSATSLoadDateTime: msatsLoadDateTime,
 	}, nil
 }
 
-func (p *Parser) parse400Record(record []string, currentDetails *NMIDataDetailsRecord) (*IntervalEventRecord, error) {
+func (p *Parser) parse400Record(record *rawRecord, currentInterval *IntervalDataRecord) (*IntervalEventRecord, error) {
 	// Mandatory field
-	startInterval, err := strconv.Atoi(record[1])
+	startInterval, err := strconv.Atoi(record.field(1))
 	if err != nil {
-		return nil, errors.New("start interval cannot be parsed")
+		return nil, record.errorAt(1, errors.New("start interval cannot be parsed"))
 	}
-	endInterval, err := strconv.Atoi(record[2])
+	endInterval, err := strconv.Atoi(record.field(2))
 	if err != nil {
-		return nil, errors.New("end interval cannot be parsed")
+		return nil, record.errorAt(2, errors.New("end interval cannot be parsed"))
+	}
+	// Intervals are 1-indexed and closed
+	if startInterval < 1 || startInterval > endInterval {
+		return nil, record.errorAt(1, errors.New("start interval out of range"))
+	}
+	if endInterval > len(currentInterval.IntervalValues) {
+		return nil, record.errorAt(2, errors.New("end interval out of range"))
 	}
 	var reasonCode *int
-	if record[4] != "" {
-		reasonCodeInt, err := strconv.Atoi(record[4])
+	if record.field(4) != "" {
+		reasonCodeInt, err := strconv.Atoi(record.field(4))
 		if err != nil {
-			return nil, errors.New("reason code cannot be parsed")
+			return nil, record.errorAt(4, errors.New("reason code cannot be parsed"))
 		}
 		reasonCode = &reasonCodeInt
 	}
 	return &IntervalEventRecord{
 		StartInterval:     startInterval,
 		EndInterval:       endInterval,
-		QualityMethod:     record[3],
+		QualityMethod:     record.field(3),
 		ReasonCode:        reasonCode,
-		ReasonDescription: record[5],
+		ReasonDescription: record.field(5),
+	}, nil
+}
+
+func (p *Parser) parse500Record(record *rawRecord) (*B2bDetailsRecord, *ParseError) {
+	readDateTime, err := parseOptionalTime(record.field(3), dateTime14Format)
+	if err != nil {
+		return nil, record.errorAt(3, errors.New("read date time cannot be parsed"))
+	}
+	return &B2bDetailsRecord{
+		TransCode:       record.field(1),
+		RetServiceOrder: record.field(2),
+		ReadDataTime:    readDateTime,
+		IndexRead:       record.field(4),
 	}, nil
 }
 
@@ -273,7 +754,7 @@ func (p *Parser) adjustInterval(currentInterval *IntervalDataRecord, event *Inte
 }
 
 // Converts a 300 interval data record with corresponding 200 data detail into our own hourly reading structure
-func (p *Parser) nem12IntervalToHourlyReadings(details *NMIDataDetailsRecord, interval *IntervalDataRecord) []HourlyReading {
+func (p *Parser) nem12IntervalToHourlyReadings(details *NMIDataDetailsRecord, interval *IntervalDataRecord) ([]HourlyReading, error) {
 	var err error
 	readingsPerHour := 60 / details.IntervalLength
 	hourlyReadings := make([]HourlyReading, 24)
@@ -310,8 +791,9 @@ func (p *Parser) nem12IntervalToHourlyReadings(details *NMIDataDetailsRecord, in
 		}
 		energyTotal, err = convertEnergy(energyTotal, details.UOM)
 		if err != nil {
-			p.logger.Error(err.Error())
-			continue
+			// The unit is checked when the 200 record is parsed, so this only happens for blocks that
+			// were put together by hand
+			return nil, err
 		}
 		hourlyReadings[hr] = HourlyReading{
 			StartTime:         startTime,
@@ -323,18 +805,68 @@ func (p *Parser) nem12IntervalToHourlyReadings(details *NMIDataDetailsRecord, in
 		}
 	}
 
-	return hourlyReadings
+	return hourlyReadings, nil
 }
 
+// Converts energy to kWh, or reactive energy to kvarh
 func convertEnergy(energy float64, uom string) (float64, error) {
 	switch strings.ToLower(uom) {
-	case "wh":
+	case "wh", "varh":
 		return energy / 1000, nil
-	case "kwh":
+	case "kwh", "kvarh":
 		return energy, nil
-	case "mhh":
+	case "mwh", "mvarh":
 		return energy * 1000, nil
 	default:
 		return 0, fmt.Errorf("unsupported unit: %v", uom)
 	}
 }
+
+// Returns the field at the given index, or an empty string if the record doesn't have that many
+// fields. Trailing optional fields are often left off entirely.
+func (r *rawRecord) field(i int) string {
+	if i < 0 || i >= len(r.fields) {
+		return ""
+	}
+	return r.fields[i]
+}
+
+func (r *rawRecord) requiredField(i int) (string, error) {
+	val := r.field(i)
+	if val == "" {
+		return "", r.errorAt(i, errMissingField)
+	}
+	return val, nil
+}
+
+func (r *rawRecord) errorAt(field int, err error) *ParseError {
+	return &ParseError{
+		Line:            r.line,
+		RecordIndicator: r.indicator,
+		Field:           field,
+		Value:           r.field(field),
+		Err:             err,
+	}
+}
+
+// Optional date and time fields are left as the zero time if they're blank
+func parseOptionalTime(val, layout string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(layout, val)
+}
+
+// Converts an error from the CSV reader into a ParseError so every error out of the parser has
+// a line number
+func readError(err error) error {
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		return &ParseError{
+			Line:  csvErr.StartLine,
+			Field: wholeRecord,
+			Err:   csvErr.Err,
+		}
+	}
+	return err
+}
@@ -0,0 +1,321 @@
+package nem12
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// Builds a 300 record for a day of 30 minute intervals, all with the same value
+func record300(date string, value string, quality string) string {
+	values := make([]string, 48)
+	for i := range values {
+		values[i] = value
+	}
+	return "300," + date + "," + strings.Join(values, ",") + "," + quality + ",,,,"
+}
+
+func nem12File(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func parseString(t *testing.T, mode ParseMode, content string) (UsageData, *Parser, error) {
+	t.Helper()
+	parser := NewParser(testLogger(), strings.NewReader(content), mode, nil)
+	data, err := parser.Parse(context.Background())
+	return data, parser, err
+}
+
+func TestParseConvertsUnits(t *testing.T) {
+	for _, tc := range []struct {
+		uom  string
+		want float64
+	}{
+		{"Wh", 0.2},
+		{"kWh", 200},
+		{"MWh", 200000},
+	} {
+		t.Run(tc.uom, func(t *testing.T) {
+			content := nem12File(
+				"100,NEM12,200301011534,MDP1,Retailer1",
+				"200,NMI1234567,E1,1,E1,N1,METER1,"+tc.uom+",30,",
+				record300("20230101", "100", "A"),
+				"900",
+			)
+			data, _, err := parseString(t, Strict, content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readings := data["NMI1234567"][GeneralUsage]
+			if len(readings) != 24 {
+				t.Fatalf("got %v readings, want 24", len(readings))
+			}
+			// Two 30 minute intervals of 100 each make up an hour
+			if readings[0].EnergyKWh != tc.want {
+				t.Errorf("got %v kWh, want %v", readings[0].EnergyKWh, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedUnit(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1,1,E1,N1,METER1,GWh,30,",
+		record300("20230101", "1", "A"),
+		"200,NMI1234567,E1,1,B1,N1,METER1,kWh,30,",
+		record300("20230101", "1", "A"),
+		"900",
+	)
+
+	_, _, err := parseString(t, Strict, content)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("strict mode got %v, want a ParseError", err)
+	}
+	if parseErr.Line != 2 || parseErr.Field != 7 {
+		t.Errorf("got error at line %v field %v, want line 2 field 7", parseErr.Line, parseErr.Field)
+	}
+
+	data, parser, err := parseString(t, Lenient, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data["NMI1234567"][GeneralUsage]) != 0 {
+		t.Errorf("lenient mode kept readings from the block with an unsupported unit")
+	}
+	if len(data["NMI1234567"][Export]) != 24 {
+		t.Errorf("lenient mode dropped the block after the one with an unsupported unit")
+	}
+	for _, readings := range data["NMI1234567"] {
+		for _, reading := range readings {
+			if reading.StartTime.Before(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("got a reading at %v", reading.StartTime)
+			}
+		}
+	}
+	skipped := parser.Report().SkippedBlocks
+	if len(skipped) != 1 || skipped[0].Line != 2 {
+		t.Errorf("got skipped blocks %+v, want the block on line 2", skipped)
+	}
+}
+
+func TestParseBadNextScheduledReadDate(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,2023XX01",
+		record300("20230101", "1", "A"),
+		"500,O,S01009,20230102120000,1234.5",
+		"900",
+	)
+
+	_, _, err := parseString(t, Strict, content)
+	if err == nil {
+		t.Error("strict mode accepted a malformed next scheduled read date")
+	}
+
+	parser := NewParser(testLogger(), strings.NewReader(content), Lenient, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parser.Report().Warnings) != 1 {
+		t.Errorf("got %v warnings, want 1", len(parser.Report().Warnings))
+	}
+	if len(file.Blocks) != 1 || len(file.Blocks[0].Intervals) != 1 {
+		t.Fatalf("lenient mode dropped the block's readings")
+	}
+	b2b := file.Blocks[0].B2bDetails
+	if len(b2b) != 1 || b2b[0].IndexRead != "1234.5" || b2b[0].TransCode != "O" {
+		t.Errorf("got B2B details %+v", b2b)
+	}
+	data := parser.ToUsageData(file)
+	if len(data["NMI1234567"][GeneralUsage]) != 24 {
+		t.Errorf("got %v readings from the parsed records, want 24", len(data["NMI1234567"][GeneralUsage]))
+	}
+}
+
+// A file with many days of readings, so the reader goroutine still has records left to send when
+// parsing stops. The tests that use it parse inside a synctest bubble, which fails the test if the
+// reader goroutine is left blocked once parsing returns.
+func longFile(days int, extra ...string) string {
+	lines := []string{"100,NEM12,200301011534,MDP1,Retailer1", "200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,"}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < days; i++ {
+		lines = append(lines, record300(start.AddDate(0, 0, i).Format(dateFormat), "1", "A"))
+	}
+	lines = append(lines, extra...)
+	return nem12File(lines...)
+}
+
+func TestParseStopsAt900WithoutLeaking(t *testing.T) {
+	// Nothing should follow the 900 record, so the reader is stopped part way through the file
+	content := longFile(10, "900", longFile(1000))
+	synctest.Test(t, func(t *testing.T) {
+		_, _, err := parseString(t, Strict, content)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 14 {
+			t.Errorf("got %v, want a ParseError for the record on line 14", err)
+		}
+	})
+	synctest.Test(t, func(t *testing.T) {
+		data, parser, err := parseString(t, Lenient, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data["NMI1234567"][GeneralUsage]) != 10*24 {
+			t.Errorf("got %v readings, want %v", len(data["NMI1234567"][GeneralUsage]), 10*24)
+		}
+		if warnings := parser.Report().Warnings; len(warnings) != 1 || warnings[0].Line != 14 {
+			t.Errorf("got warnings %v, want one for the record on line 14", warnings)
+		}
+	})
+}
+
+func TestParseStrictErrorWithoutLeaking(t *testing.T) {
+	content := longFile(10, "300,2023XX01,1") + longFile(1000)
+	synctest.Test(t, func(t *testing.T) {
+		_, _, err := parseString(t, Strict, content)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 13 {
+			t.Errorf("got %v, want a ParseError on line 13", err)
+		}
+	})
+}
+
+// Cancels a context once a number of bytes have been read through it
+type cancellingReader struct {
+	*strings.Reader
+	cancel func()
+	after  int64
+	read   int64
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read = r.read + int64(n)
+	if r.read > r.after {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestParseCancelledWithoutLeaking(t *testing.T) {
+	content := longFile(2000, "900")
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reader := &cancellingReader{Reader: strings.NewReader(content), cancel: cancel, after: int64(len(content) / 2)}
+		parser := NewParser(testLogger(), reader, Strict, nil)
+		_, err := parser.Parse(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestParseReadErrorWithoutLeaking(t *testing.T) {
+	// A bare quote in a field is a CSV error, which has to come back from the reader goroutine
+	badRecord := `300,20230111,1"`
+	synctest.Test(t, func(t *testing.T) {
+		_, _, err := parseString(t, Strict, longFile(10, badRecord)+longFile(1000))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 13 {
+			t.Errorf("got %v, want the read error as a ParseError on line 13", err)
+		}
+	})
+
+	// Lenient mode drops the record and carries on. The 400 record can't be applied because it might
+	// belong to the record that was dropped.
+	content := longFile(10, badRecord, "400,1,48,A,,", record300("20230112", "1", "A"), "900")
+	synctest.Test(t, func(t *testing.T) {
+		data, parser, err := parseString(t, Lenient, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readings := data["NMI1234567"][GeneralUsage]
+		if len(readings) != 11*24 || !readings[len(readings)-1].StartTime.Equal(time.Date(2023, 1, 12, 23, 0, 0, 0, time.UTC)) {
+			t.Errorf("got %v readings, want the 11 good days", len(readings))
+		}
+		if warnings := parser.Report().Warnings; len(warnings) != 1 || warnings[0].Line != 13 {
+			t.Errorf("got warnings %v, want one for the record on line 13", warnings)
+		}
+	})
+}
+
+func TestParseMissingQualityMethod(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,",
+		record300("20230101", "1", ""),
+		"900",
+	)
+	_, _, err := parseString(t, Strict, content)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 || parseErr.Field != 50 {
+		t.Errorf("got %v, want a ParseError for the quality method on line 3", err)
+	}
+
+	data, parser, err := parseString(t, Lenient, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readings := data["NMI1234567"][GeneralUsage]
+	if len(readings) != 24 || readings[0].IsActual() {
+		t.Errorf("got %v readings, want 24 that aren't counted as actual", len(readings))
+	}
+	if len(parser.Report().Warnings) != 1 {
+		t.Errorf("got %v warnings, want 1", len(parser.Report().Warnings))
+	}
+}
+
+func TestParseLenientReport(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1Z9,1,E1,N1,METER1,kWh,30,",
+		record300("20230101", "1", "V"),
+		"400,1,2,E52,,",
+		"400,3,48,A,,",
+		record300("2023XX02", "1", "A"),
+		"200,NMI1234567,E1Z9,1,Z9,N1,METER1,kWh,30,",
+		record300("20230101", "1", "A"),
+	)
+	data, parser, err := parseString(t, Lenient, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readings := data["NMI1234567"][GeneralUsage]
+	if len(readings) != 24 {
+		t.Fatalf("got %v readings, want 24", len(readings))
+	}
+	if readings[0].IsActual() || len(readings[0].QualityMethod) != 1 || readings[0].QualityMethod[0] != "E52" {
+		t.Errorf("got quality %v for the estimated hour", readings[0].QualityMethod)
+	}
+	if !readings[1].IsActual() {
+		t.Errorf("got quality %v for an actual hour", readings[1].QualityMethod)
+	}
+
+	report := parser.Report()
+	if len(report.DroppedIntervals) != 1 || report.DroppedIntervals[0].Line != 6 {
+		t.Errorf("got dropped intervals %+v, want the 300 record on line 6", report.DroppedIntervals)
+	}
+	if len(report.UnsupportedSuffixes) != 1 || report.UnsupportedSuffixes[0] != "Z9" {
+		t.Errorf("got unsupported suffixes %v, want Z9", report.UnsupportedSuffixes)
+	}
+	if !report.Missing900 {
+		t.Error("didn't report the missing 900 record")
+	}
+	if report.Quality == nil {
+		t.Error("no quality summary once the readings were built")
+	}
+}
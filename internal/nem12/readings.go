@@ -1,39 +1,140 @@
 package nem12
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
 
 // National Meter Identifier. Unique for each connection point.
 type NMI string
 
-// Describes the type of interval data the record applies to. Mapped from the NMI suffix.
+// Describes the type of interval data the record applies to. Mapped from the NMI suffix using a
+// SuffixMapping.
 type ReadingType string
 
-// We interpret NMI suffixes in a broad, generalised way that will be mostly correct for our purposes
 const (
-	GeneralUsage    ReadingType = "E1"
-	ControlledLoad  ReadingType = "E2"
-	PrimaryExport   ReadingType = "B1"
-	SecondaryExport ReadingType = "B2"
+	GeneralUsage   ReadingType = "import"
+	ControlledLoad ReadingType = "controlledLoad"
+	Export         ReadingType = "export"
+	// Reactive energy is measured in kvarh rather than kWh
+	ReactiveImport ReadingType = "reactiveImport"
+	ReactiveExport ReadingType = "reactiveExport"
 )
 
-func IsValidReadingType(val string) bool {
-	switch val {
-	case string(GeneralUsage), string(ControlledLoad), string(PrimaryExport), string(SecondaryExport):
-		return true
+// Maps NMI suffixes to the type of reading they hold. The first character of a suffix identifies
+// what's being measured (e.g. E for import, B for export) and the second identifies the meter
+// element, but what each element is used for varies between meters so this can be configured.
+type SuffixMapping struct {
+	// Mappings for specific suffixes, e.g. E2. These take precedence over Prefixes.
+	Suffixes map[string]ReadingType
+	// Mappings by the first character of the suffix, e.g. E
+	Prefixes map[string]ReadingType
+}
+
+// Interprets suffixes in a broad, generalised way that will be mostly correct for our purposes.
+// E2 is taken to be a controlled load, and every other element is added to its general type.
+func DefaultSuffixMapping() *SuffixMapping {
+	return &SuffixMapping{
+		Suffixes: map[string]ReadingType{
+			"E2": ControlledLoad,
+		},
+		Prefixes: map[string]ReadingType{
+			"E": GeneralUsage,
+			"B": Export,
+			"Q": ReactiveImport,
+			"K": ReactiveExport,
+		},
+	}
+}
+
+func ParseReadingType(val string) (ReadingType, error) {
+	switch ReadingType(val) {
+	case GeneralUsage, ControlledLoad, Export, ReactiveImport, ReactiveExport:
+		return ReadingType(val), nil
 	default:
-		return false
+		return "", fmt.Errorf("unknown reading type %v", val)
+	}
+}
+
+// Returns the reading type for a suffix, or false if it isn't mapped to anything
+func (m *SuffixMapping) ReadingType(suffix string) (ReadingType, bool) {
+	suffix = strings.ToUpper(suffix)
+	if readingType, ok := m.Suffixes[suffix]; ok {
+		return readingType, true
+	}
+	if len(suffix) == 0 {
+		return "", false
 	}
+	readingType, ok := m.Prefixes[suffix[:1]]
+	return readingType, ok
 }
 
+// The first character of a quality method says what kind of data a value is
+const (
+	Actual           = 'A'
+	Estimated        = 'E'
+	Substituted      = 'S'
+	FinalSubstituted = 'F'
+	Null             = 'N'
+)
+
 type HourlyReading struct {
 	StartTime time.Time
 	EndTime   time.Time
+	// For reactive reading types this is in kvarh
 	EnergyKWh float64
 	// The hourly reading can consist of multiple measurements with different quality methods and
 	// reason codes/descriptions so we include them all here
 	QualityMethod     []string
 	ReasonCode        []int
 	ReasonDescription []string
+	// Set when the reading wasn't in the data at all and has been estimated by an ImputationStrategy
+	Imputed bool
 }
 
+// Hourly readings in chronological order for each NMI and reading type. Where several suffixes map to
+// the same reading type, their readings are added together.
 type UsageData map[NMI]map[ReadingType][]HourlyReading
+
+// Whether every value that makes up the reading was an actual read, as opposed to an estimate or
+// substitution
+func (r HourlyReading) IsActual() bool {
+	if len(r.QualityMethod) == 0 {
+		return false
+	}
+	for _, method := range r.QualityMethod {
+		if method == "" || method[0] != Actual {
+			return false
+		}
+	}
+	return true
+}
+
+// Sorts readings into chronological order and combines any readings for the same hour into one. This
+// is done in place, so the returned slice shares the memory of readings.
+func aggregateReadings(readings []HourlyReading) []HourlyReading {
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].StartTime.Before(readings[j].StartTime) })
+	aggregated := readings[:0]
+	for _, reading := range readings {
+		last := len(aggregated) - 1
+		if last < 0 || !aggregated[last].StartTime.Equal(reading.StartTime) {
+			aggregated = append(aggregated, reading)
+			continue
+		}
+		aggregated[last].EnergyKWh = aggregated[last].EnergyKWh + reading.EnergyKWh
+		aggregated[last].QualityMethod = union(aggregated[last].QualityMethod, reading.QualityMethod)
+		aggregated[last].ReasonCode = union(aggregated[last].ReasonCode, reading.ReasonCode)
+		aggregated[last].ReasonDescription = union(aggregated[last].ReasonDescription, reading.ReasonDescription)
+	}
+	return aggregated
+}
+
+func union[T comparable](a, b []T) []T {
+	set := mapset.NewSet[T](a...)
+	set.Append(b...)
+	return set.ToSlice()
+}
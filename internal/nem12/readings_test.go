@@ -0,0 +1,106 @@
+package nem12
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// A site with two general usage elements and a controlled load
+var multiElementFile = nem12File(
+	"100,NEM12,200301011534,MDP1,Retailer1",
+	"200,NMI1234567,E1E2E3,1,E1,N1,METER1,kWh,30,",
+	record300("20230101", "1", "A"),
+	"200,NMI1234567,E1E2E3,1,E3,N1,METER1,kWh,30,",
+	record300("20230101", "0.5", "E52"),
+	"200,NMI1234567,E1E2E3,1,E2,N1,METER1,kWh,30,",
+	record300("20230101", "2", "A"),
+	"900",
+)
+
+func TestParseSumsElementsOfTheSameType(t *testing.T) {
+	data, _, err := parseString(t, Strict, multiElementFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	general := data["NMI1234567"][GeneralUsage]
+	if len(general) != 24 {
+		t.Fatalf("got %v general usage readings, want E1 and E3 combined into 24", len(general))
+	}
+	// Two half hours of 1 kWh from E1 and two of 0.5 kWh from E3
+	if general[0].EnergyKWh != 3 {
+		t.Errorf("got %v kWh, want 3", general[0].EnergyKWh)
+	}
+	// The combined hour is only as good as its worst element
+	if !slices.Contains(general[0].QualityMethod, "A") || !slices.Contains(general[0].QualityMethod, "E52") || general[0].IsActual() {
+		t.Errorf("got quality methods %v, want both elements' methods", general[0].QualityMethod)
+	}
+	controlled := data["NMI1234567"][ControlledLoad]
+	if len(controlled) != 24 || controlled[0].EnergyKWh != 4 {
+		t.Errorf("got %v controlled load readings starting at %v kWh, want 24 at 4", len(controlled), controlled[0].EnergyKWh)
+	}
+}
+
+func TestParseSuffixOverride(t *testing.T) {
+	// The controlled load is really a second general usage element
+	suffixes := DefaultSuffixMapping()
+	suffixes.Suffixes["E2"] = GeneralUsage
+	parser := NewParser(testLogger(), strings.NewReader(multiElementFile), Strict, suffixes)
+	data, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["NMI1234567"][ControlledLoad]; ok {
+		t.Error("E2 is still a controlled load")
+	}
+	general := data["NMI1234567"][GeneralUsage]
+	if len(general) != 24 || general[0].EnergyKWh != 7 {
+		t.Errorf("got %v general usage readings starting at %v kWh, want 24 at 7", len(general), general[0].EnergyKWh)
+	}
+}
+
+func TestSuffixMappingReadingType(t *testing.T) {
+	suffixes := DefaultSuffixMapping()
+	suffixes.Prefixes["B"] = GeneralUsage
+	suffixes.Suffixes["B2"] = Export
+	for _, tc := range []struct {
+		suffix string
+		want   ReadingType
+		ok     bool
+	}{
+		{"E1", GeneralUsage, true},
+		{"e2", ControlledLoad, true},
+		{"B1", GeneralUsage, true},
+		{"B2", Export, true},
+		{"Q1", ReactiveImport, true},
+		{"X1", "", false},
+		{"", "", false},
+	} {
+		got, ok := suffixes.ReadingType(tc.suffix)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("got %q, %v for %q, want %q, %v", got, ok, tc.suffix, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestIsActual(t *testing.T) {
+	for _, tc := range []struct {
+		methods []string
+		want    bool
+	}{
+		{[]string{"A"}, true},
+		{[]string{"A", "A"}, true},
+		{[]string{"A", "E52"}, false},
+		{[]string{"S14"}, false},
+		{[]string{"F15"}, false},
+		{[]string{"N"}, false},
+		{[]string{""}, false},
+		// Imputed readings have no quality method
+		{nil, false},
+	} {
+		if got := (HourlyReading{QualityMethod: tc.methods}).IsActual(); got != tc.want {
+			t.Errorf("got %v for quality methods %v, want %v", got, tc.methods, tc.want)
+		}
+	}
+}
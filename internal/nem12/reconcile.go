@@ -0,0 +1,54 @@
+package nem12
+
+import "time"
+
+// Removes any interval that has been superseded by another interval for the same NMI, suffix and
+// date. This happens when, for example, an estimate is later replaced by an actual read and both
+// end up in the same export. The interval with the latest UpdateDateTime (then MSATSLoadDateTime)
+// is kept, and if they're the same the one that appears last in the file wins. Returns the number
+// of intervals removed.
+func (f *File) Reconcile() int {
+	type intervalKey struct {
+		nmi    string
+		suffix string
+		date   time.Time
+	}
+	latest := make(map[intervalKey]*IntervalDataRecord)
+	for _, block := range f.Blocks {
+		for _, interval := range block.Intervals {
+			key := intervalKey{block.Details.NMI, block.Details.NMISuffix, interval.IntervalDate}
+			existing, ok := latest[key]
+			if !ok || !existing.updatedAfter(interval) {
+				latest[key] = interval
+			}
+		}
+	}
+
+	removed := 0
+	for _, block := range f.Blocks {
+		kept := make([]*IntervalDataRecord, 0, len(block.Intervals))
+		for _, interval := range block.Intervals {
+			key := intervalKey{block.Details.NMI, block.Details.NMISuffix, interval.IntervalDate}
+			if latest[key] != interval {
+				removed = removed + 1
+				continue
+			}
+			kept = append(kept, interval)
+		}
+		block.Intervals = kept
+	}
+	return removed
+}
+
+func (r *IntervalDataRecord) updatedAfter(other *IntervalDataRecord) bool {
+	return updatedAfter(r.UpdateDateTime, r.MSATSLoadDateTime, other.UpdateDateTime, other.MSATSLoadDateTime)
+}
+
+// Whether one version of a day's data is newer than another, going by UpdateDateTime and then
+// MSATSLoadDateTime
+func updatedAfter(update, msatsLoad, otherUpdate, otherMsatsLoad time.Time) bool {
+	if !update.Equal(otherUpdate) {
+		return update.After(otherUpdate)
+	}
+	return msatsLoad.After(otherMsatsLoad)
+}
@@ -0,0 +1,88 @@
+package nem12
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Builds a 300 record like record300, with the given UpdateDateTime and MSATSLoadDateTime
+func updatedRecord300(date string, value string, quality string, updated string, msatsLoad string) string {
+	return strings.TrimSuffix(record300(date, value, quality), ",,") + "," + updated + "," + msatsLoad
+}
+
+func TestReconcileKeepsLatestUpdate(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,",
+		updatedRecord300("20230101", "3", "A", "20230105000000", ""),
+		// An estimate loaded earlier, appearing after the actual read that replaced it
+		updatedRecord300("20230101", "1", "E", "20230102000000", ""),
+		// Same update time, so the later MSATS load wins
+		updatedRecord300("20230102", "1", "A", "20230105000000", "20230106000000"),
+		updatedRecord300("20230102", "2", "A", "20230105000000", "20230107000000"),
+		// Nothing to tell them apart, so the last one wins
+		record300("20230103", "1", "A"),
+		record300("20230103", "4", "A"),
+		"900",
+	)
+
+	parser := NewParser(testLogger(), strings.NewReader(content), Strict, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := file.Reconcile()
+	if removed != 3 {
+		t.Errorf("removed %v intervals, want 3", removed)
+	}
+	want := map[string]float64{"20230101": 3, "20230102": 2, "20230103": 4}
+	intervals := file.Blocks[0].Intervals
+	if len(intervals) != len(want) {
+		t.Fatalf("kept %v intervals, want %v", len(intervals), len(want))
+	}
+	for _, interval := range intervals {
+		date := interval.IntervalDate.Format(dateFormat)
+		if interval.IntervalValues[0].Value != want[date] {
+			t.Errorf("kept %v for %v, want %v", interval.IntervalValues[0].Value, date, want[date])
+		}
+	}
+
+	// Parsing straight to readings has to come to the same answer
+	data, parser, err := parseString(t, Strict, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser.Report().ReplacedIntervals != 3 {
+		t.Errorf("replaced %v intervals while parsing, want 3", parser.Report().ReplacedIntervals)
+	}
+	readings := data["NMI1234567"][GeneralUsage]
+	if len(readings) != 3*24 {
+		t.Fatalf("got %v readings, want %v", len(readings), 3*24)
+	}
+	for _, reading := range readings {
+		date := reading.StartTime.Format(dateFormat)
+		if reading.EnergyKWh != 2*want[date] {
+			t.Errorf("got %v kWh at %v, want %v", reading.EnergyKWh, reading.StartTime, 2*want[date])
+		}
+	}
+}
+
+func TestReconcileKeepsSuffixesApart(t *testing.T) {
+	content := nem12File(
+		"100,NEM12,200301011534,MDP1,Retailer1",
+		"200,NMI1234567,E1B1,1,E1,N1,METER1,kWh,30,",
+		record300("20230101", "1", "A"),
+		"200,NMI1234567,E1B1,1,B1,N1,METER1,kWh,30,",
+		record300("20230101", "2", "A"),
+		"900",
+	)
+	parser := NewParser(testLogger(), strings.NewReader(content), Strict, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := file.Reconcile(); removed != 0 {
+		t.Errorf("removed %v intervals from different suffixes", removed)
+	}
+}
@@ -4,6 +4,30 @@ import (
 	"time"
 )
 
+// Date and time formats used by the fields in the MDFF specification
+const (
+	dateFormat       = "20060102"
+	dateTime12Format = "200601021504"
+	dateTime14Format = "20060102150405"
+)
+
+// A NEM12 file in terms of its records, before any interpretation into hourly readings. This
+// holds everything needed to write the file back out again.
+type File struct {
+	// Not every NEM12 file has a header so this may be nil
+	Header *HeaderRecord
+	Blocks []*NMIDataBlock
+}
+
+// A 200 record along with all the 300 records (and their 400 events) and 500 records that belong to it
+type NMIDataBlock struct {
+	Details    NMIDataDetailsRecord
+	Intervals  []*IntervalDataRecord
+	B2bDetails []*B2bDetailsRecord
+	// 1-indexed line number of the 200 record, if the block was parsed from a file
+	line int
+}
+
 // Header record (100)
 type HeaderRecord struct {
 	VersionHeader   string
@@ -64,4 +88,10 @@ type B2bDetailsRecord struct {
 	RetServiceOrder string
 	ReadDataTime    time.Time
 	IndexRead       string
+	// The 300 record this followed in the file, so it can be written back in the same place. Nil if
+	// it came straight after the 200 record.
+	after *IntervalDataRecord
+	// Whether after has been set, which is only the case for records that were parsed. Others are
+	// written at the end of their block.
+	placed bool
 }
@@ -0,0 +1,80 @@
+package nem12
+
+import (
+	"fmt"
+	"time"
+)
+
+// Replaces identifying values in a parsed NEM12 file so it can be shared. Each distinct value is
+// given a pseudonym the first time it's seen and that same pseudonym is used for every later
+// occurrence, so the structure of the file (e.g. which blocks belong to the same NMI) is kept.
+// Interval values and quality data are never touched.
+type Redactor struct {
+	// Number of days to move every date in the file by. Zero leaves dates as they are.
+	shiftDays int
+	// Original value to pseudonym, kept separately for each kind of identifier
+	nmis         map[string]string
+	meterSerials map[string]string
+	participants map[string]string
+	registerIDs  map[string]string
+	orders       map[string]string
+}
+
+func NewRedactor(shiftDays int) *Redactor {
+	return &Redactor{
+		shiftDays:    shiftDays,
+		nmis:         make(map[string]string),
+		meterSerials: make(map[string]string),
+		participants: make(map[string]string),
+		registerIDs:  make(map[string]string),
+		orders:       make(map[string]string),
+	}
+}
+
+// Redacts the file in place
+func (r *Redactor) Redact(file *File) {
+	if file.Header != nil {
+		file.Header.FromParticipant = pseudonym(r.participants, file.Header.FromParticipant, "PARTY")
+		file.Header.ToParticipant = pseudonym(r.participants, file.Header.ToParticipant, "PARTY")
+		file.Header.DateTime = r.shift(file.Header.DateTime)
+	}
+	for _, block := range file.Blocks {
+		details := &block.Details
+		details.NMI = pseudonym(r.nmis, details.NMI, "NMI")
+		details.MeterSerialNumber = pseudonym(r.meterSerials, details.MeterSerialNumber, "METER")
+		details.RegisterID = pseudonym(r.registerIDs, details.RegisterID, "R")
+		details.NextScheduledReadDate = r.shift(details.NextScheduledReadDate)
+		for _, interval := range block.Intervals {
+			interval.IntervalDate = r.shift(interval.IntervalDate)
+			interval.UpdateDateTime = r.shift(interval.UpdateDateTime)
+			interval.MSATSLoadDateTime = r.shift(interval.MSATSLoadDateTime)
+		}
+		for _, b2b := range block.B2bDetails {
+			b2b.RetServiceOrder = pseudonym(r.orders, b2b.RetServiceOrder, "SO")
+			b2b.ReadDataTime = r.shift(b2b.ReadDataTime)
+		}
+	}
+}
+
+func (r *Redactor) shift(t time.Time) time.Time {
+	// Unset optional fields must stay unset
+	if t.IsZero() {
+		return t
+	}
+	return t.AddDate(0, 0, r.shiftDays)
+}
+
+// Looks up the pseudonym for a value, creating a new one from the prefix and a sequence number if
+// the value hasn't been seen before. Empty values are left empty. Pseudonyms are always 10
+// characters long, which is the length of an NMI and fits within the other identifier fields.
+func pseudonym(pseudonyms map[string]string, value, prefix string) string {
+	if value == "" {
+		return value
+	}
+	if p, ok := pseudonyms[value]; ok {
+		return p
+	}
+	p := fmt.Sprintf("%v%0*d", prefix, 10-len(prefix), len(pseudonyms)+1)
+	pseudonyms[value] = p
+	return p
+}
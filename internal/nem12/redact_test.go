@@ -0,0 +1,144 @@
+package nem12
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactorKeepsPseudonymsAcrossFiles(t *testing.T) {
+	original, err := os.ReadFile("testdata/roundtrip.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := parseRecordsFrom(t, original)
+	// A later file from the same MDP, with the same meter and a second site
+	second := parseRecordsFrom(t, []byte(nem12File(
+		"100,NEM12,200304011534,MDP1,Retailer2",
+		"200,NMI7654321,E1,1,E1,N1,METER2,kWh,30,",
+		record300("20230401", "1", "A"),
+		"200,NMI1234567,E1,1,E1,N1,METER1,kWh,30,",
+		record300("20230401", "1", "A"),
+		"900",
+	)))
+	redactor := NewRedactor(0)
+	redactor.Redact(first)
+	redactor.Redact(second)
+
+	site := first.Blocks[0].Details
+	if site.NMI == "NMI1234567" || len(site.NMI) != 10 || site.MeterSerialNumber == "METER1" {
+		t.Fatalf("got NMI %v and meter %v", site.NMI, site.MeterSerialNumber)
+	}
+	if first.Blocks[1].Details.NMI != site.NMI {
+		t.Errorf("got NMIs %v and %v for the same site in one file", site.NMI, first.Blocks[1].Details.NMI)
+	}
+	other := second.Blocks[0].Details
+	same := second.Blocks[1].Details
+	if same.NMI != site.NMI || same.MeterSerialNumber != site.MeterSerialNumber {
+		t.Errorf("got NMI %v and meter %v in the second file, want %v and %v as in the first",
+			same.NMI, same.MeterSerialNumber, site.NMI, site.MeterSerialNumber)
+	}
+	if other.NMI == site.NMI || other.MeterSerialNumber == site.MeterSerialNumber {
+		t.Errorf("gave the second site the same NMI %v or meter %v as the first", other.NMI, other.MeterSerialNumber)
+	}
+	if first.Header.FromParticipant != second.Header.FromParticipant ||
+		first.Header.ToParticipant == second.Header.ToParticipant {
+		t.Errorf("got participants %v to %v and %v to %v", first.Header.FromParticipant, first.Header.ToParticipant,
+			second.Header.FromParticipant, second.Header.ToParticipant)
+	}
+
+	var written bytes.Buffer
+	err = NewWriter(&written).WriteFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"NMI1234567", "METER1", "MDP1", "Retailer1", "S01009", "SO2"} {
+		if strings.Contains(written.String(), value) {
+			t.Errorf("the redacted file still contains %v", value)
+		}
+	}
+	// The readings themselves are what make a file useful for debugging
+	if !strings.Contains(written.String(), "400,11,20,S14,53,Meter faulty") {
+		t.Errorf("the redacted file lost its quality data:\n%v", written.String())
+	}
+	if first.Blocks[0].Intervals[0].IntervalValues[1].Value != 0.25 {
+		t.Errorf("got interval value %v, want 0.25", first.Blocks[0].Intervals[0].IntervalValues[1].Value)
+	}
+}
+
+func TestRedactorShiftsDates(t *testing.T) {
+	original, err := os.ReadFile("testdata/roundtrip.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := parseRecordsFrom(t, original)
+	NewRedactor(14).Redact(file)
+
+	shifted := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day+14, hour, min, sec, 0, time.UTC)
+	}
+	if !file.Header.DateTime.Equal(shifted(2003, time.January, 1, 15, 34, 0)) {
+		t.Errorf("got header date time %v", file.Header.DateTime)
+	}
+	block := file.Blocks[0]
+	if !block.Details.NextScheduledReadDate.Equal(shifted(2023, time.April, 1, 0, 0, 0)) {
+		t.Errorf("got next scheduled read date %v", block.Details.NextScheduledReadDate)
+	}
+	interval := block.Intervals[0]
+	if !interval.IntervalDate.Equal(shifted(2023, time.January, 1, 0, 0, 0)) ||
+		!interval.UpdateDateTime.Equal(shifted(2023, time.January, 2, 3, 4, 5)) ||
+		!interval.MSATSLoadDateTime.Equal(shifted(2023, time.January, 2, 4, 5, 6)) {
+		t.Errorf("got interval date %v updated at %v and loaded at %v", interval.IntervalDate,
+			interval.UpdateDateTime, interval.MSATSLoadDateTime)
+	}
+	if !block.B2bDetails[0].ReadDataTime.Equal(shifted(2023, time.January, 2, 12, 0, 0)) {
+		t.Errorf("got read date time %v", block.B2bDetails[0].ReadDataTime)
+	}
+	// Dates that weren't given must stay that way
+	if !file.Blocks[1].Details.NextScheduledReadDate.IsZero() {
+		t.Errorf("got next scheduled read date %v for a block without one", file.Blocks[1].Details.NextScheduledReadDate)
+	}
+}
+
+func TestParseReportLossless(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		lines    []string
+		lossless bool
+	}{
+		{"clean", []string{record300("20230101", "1", "A"), "900"}, true},
+		// The writer adds the 900 record back in
+		{"missing 900", []string{record300("20230101", "1", "A")}, true},
+		{"dropped 300 record", []string{record300("2023XX01", "1", "A"), record300("20230102", "1", "A"), "900"}, false},
+		{"blanked out field", []string{record300("20230101", "1", "A"), "500,O,S01009,2023XX02120000,1234.5", "900"}, false},
+		{"missing 900 and more", []string{record300("20230101", "1", "A"), "500,O,S01009,2023XX02120000,1234.5"}, false},
+		{"skipped block", []string{
+			record300("20230101", "1", "A"),
+			"200,NMI1234567,E1B1,1,B1,N1,METER1,GWh,30,",
+			record300("20230101", "1", "A"),
+			"900",
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content := nem12File(append([]string{
+				"100,NEM12,200301011534,MDP1,Retailer1",
+				"200,NMI1234567,E1B1,1,E1,N1,METER1,kWh,30,",
+			}, tc.lines...)...)
+			parser := NewParser(testLogger(), strings.NewReader(content), Lenient, nil)
+			_, err := parser.ParseFile(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = parser.Report().Lossless()
+			if tc.lossless && err != nil {
+				t.Errorf("got %v for a file that can be written back out as it was", err)
+			}
+			if !tc.lossless && err == nil {
+				t.Errorf("got no error with report %+v", parser.Report())
+			}
+		})
+	}
+}
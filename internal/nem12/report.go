@@ -0,0 +1,63 @@
+package nem12
+
+import "fmt"
+
+// A summary of everything the parser had to skip or work around while parsing a file
+type ParseReport struct {
+	// 200 blocks that weren't converted into readings
+	SkippedBlocks []SkippedBlock
+	// 300 records that were dropped in lenient mode because they, or one of their 400 records,
+	// were malformed
+	DroppedIntervals []DroppedInterval
+	// The number of 300 records that were replaced by a later version of the same day
+	ReplacedIntervals int
+	// The distinct NMI suffixes that we don't know how to interpret
+	UnsupportedSuffixes []string
+	// The file ended without a 900 end of data record
+	Missing900 bool
+	// Problems that didn't stop the file from being parsed
+	Warnings []*ParseError
+	// The estimates, substitutions and reason codes in the readings. Only set once the records have
+	// been converted into readings.
+	Quality *QualitySummary
+}
+
+type SkippedBlock struct {
+	// 1-indexed line number of the block's 200 record
+	Line   int
+	NMI    string
+	Suffix string
+	Reason string
+}
+
+type DroppedInterval struct {
+	// 1-indexed line number of the 300 record
+	Line   int
+	NMI    string
+	Suffix string
+	Err    error
+}
+
+func (r *ParseReport) addUnsupportedSuffix(suffix string) {
+	for _, s := range r.UnsupportedSuffixes {
+		if s == suffix {
+			return
+		}
+	}
+	r.UnsupportedSuffixes = append(r.UnsupportedSuffixes, suffix)
+}
+
+// Checks that nothing was dropped or worked around, so the parsed file can be written back out
+// without changing it. A missing 900 record is allowed, as the writer puts it right. Use this before
+// rewriting a file that was parsed in lenient mode, which drops or blanks out bad data.
+func (r *ParseReport) Lossless() error {
+	workarounds := len(r.Warnings)
+	if r.Missing900 {
+		workarounds = workarounds - 1
+	}
+	if len(r.DroppedIntervals) > 0 || len(r.SkippedBlocks) > 0 || workarounds > 0 {
+		return fmt.Errorf("%v dropped 300 records, %v skipped blocks and %v other problems",
+			len(r.DroppedIntervals), len(r.SkippedBlocks), workarounds)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package nem12
+
+import "time"
+
+// The readings for one NMI, suffix and day, as given by a single 300 record
+type DayReadings struct {
+	NMI         NMI
+	Suffix      string
+	ReadingType ReadingType
+	Date        time.Time
+	Readings    []HourlyReading
+	// When this version of the day's data was last updated. Used to pick the latest version when
+	// the same day appears more than once.
+	UpdateDateTime    time.Time
+	MSATSLoadDateTime time.Time
+}
+
+// Collects days of readings into usage data, keeping only the latest version of each day
+type usageBuilder struct {
+	days map[dayKey]*DayReadings
+	// The number of days replaced by a later version
+	replaced int
+}
+
+type dayKey struct {
+	nmi    NMI
+	suffix string
+	date   time.Time
+}
+
+func newUsageBuilder() *usageBuilder {
+	return &usageBuilder{
+		days: make(map[dayKey]*DayReadings),
+	}
+}
+
+// Has the signature of a Stream callback so it can be passed straight in
+func (b *usageBuilder) add(day *DayReadings) error {
+	key := dayKey{day.NMI, day.Suffix, day.Date}
+	existing, ok := b.days[key]
+	if ok {
+		b.replaced = b.replaced + 1
+		if updatedAfter(existing.UpdateDateTime, existing.MSATSLoadDateTime, day.UpdateDateTime, day.MSATSLoadDateTime) {
+			return nil
+		}
+	}
+	b.days[key] = day
+	return nil
+}
+
+// Builds the usage data from the days collected so far. Each day is released once its readings have
+// been copied over, so the builder is empty afterwards and the readings are only held once.
+func (b *usageBuilder) build() UsageData {
+	// Sizing each slice up front means appending never has to copy what's already there
+	counts := make(map[NMI]map[ReadingType]int)
+	for _, day := range b.days {
+		if counts[day.NMI] == nil {
+			counts[day.NMI] = make(map[ReadingType]int)
+		}
+		counts[day.NMI][day.ReadingType] = counts[day.NMI][day.ReadingType] + len(day.Readings)
+	}
+	data := make(UsageData)
+	for nmi, readingTypes := range counts {
+		data[nmi] = make(map[ReadingType][]HourlyReading)
+		for readingType, count := range readingTypes {
+			data[nmi][readingType] = make([]HourlyReading, 0, count)
+		}
+	}
+	for key, day := range b.days {
+		data[day.NMI][day.ReadingType] = append(data[day.NMI][day.ReadingType], day.Readings...)
+		delete(b.days, key)
+	}
+	// Several suffixes can map to the same reading type, so we put everything in order and add up
+	// any readings for the same hour
+	for _, readingTypes := range data {
+		for readingType, readings := range readingTypes {
+			readingTypes[readingType] = aggregateReadings(readings)
+		}
+	}
+	return data
+}
@@ -0,0 +1,84 @@
+package nem12
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+// Counts the bytes read through it, which may happen on another goroutine
+type countingReader struct {
+	*strings.Reader
+	read atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+func TestStreamPassesOnEachDay(t *testing.T) {
+	content := longFile(1000, record300("20230101", "2", "A"), "900")
+	reader := &countingReader{Reader: strings.NewReader(content)}
+	parser := NewParser(testLogger(), reader, Strict, nil)
+
+	var days []*DayReadings
+	var readAtFirstDay int64
+	err := parser.Stream(context.Background(), func(day *DayReadings) error {
+		if len(days) == 0 {
+			readAtFirstDay = reader.read.Load()
+		}
+		days = append(days, day)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reader only runs a buffer's worth ahead, so the first day arrives long before the end
+	if readAtFirstDay >= int64(len(content)/2) {
+		t.Errorf("got the first day after reading %v of %v bytes", readAtFirstDay, len(content))
+	}
+	// One call for each 300 record, including the second version of the first day
+	if len(days) != 1001 {
+		t.Fatalf("got %v days, want 1001", len(days))
+	}
+	for i, day := range days[:1000] {
+		if want := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i); !day.Date.Equal(want) {
+			t.Fatalf("got day %v for the 300 record on line %v, want %v", day.Date, i+3, want)
+		}
+	}
+	last := days[1000]
+	if !last.Date.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) || len(last.Readings) != 24 || last.Readings[0].EnergyKWh != 4 {
+		t.Errorf("got %+v for the second version of the first day", last)
+	}
+	if last.NMI != "NMI1234567" || last.Suffix != "E1" || last.ReadingType != GeneralUsage {
+		t.Errorf("got NMI %v, suffix %v and reading type %v", last.NMI, last.Suffix, last.ReadingType)
+	}
+}
+
+func TestStreamStopsWhenFnFails(t *testing.T) {
+	content := longFile(1000, "900")
+	stop := errors.New("stop")
+	synctest.Test(t, func(t *testing.T) {
+		parser := NewParser(testLogger(), strings.NewReader(content), Strict, nil)
+		calls := 0
+		err := parser.Stream(context.Background(), func(day *DayReadings) error {
+			calls = calls + 1
+			if calls == 3 {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("got %v, want the error from fn", err)
+		}
+		if calls != 3 {
+			t.Errorf("fn was called %v times, want parsing to stop after the error on the 3rd", calls)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package nem12
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Writer struct {
+	csv *csv.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		csv: csv.NewWriter(w),
+	}
+}
+
+// Writes a complete NEM12 file, from the 100 header record through to the 900 end record
+func (w *Writer) WriteFile(file *File) error {
+	err := w.WriteHeader(file.Header)
+	if err != nil {
+		return err
+	}
+	for _, block := range file.Blocks {
+		err = w.WriteBlock(block)
+		if err != nil {
+			return err
+		}
+	}
+	return w.WriteEnd()
+}
+
+// Writes the 100 header record. If the header is nil, a header with only the version is written.
+func (w *Writer) WriteHeader(header *HeaderRecord) error {
+	if header == nil {
+		header = &HeaderRecord{}
+	}
+	version := header.VersionHeader
+	if version == "" {
+		version = "NEM12"
+	}
+	return w.csv.Write([]string{
+		"100",
+		version,
+		formatTime(header.DateTime, dateTime12Format),
+		header.FromParticipant,
+		header.ToParticipant,
+	})
+}
+
+// Writes a 200 record followed by its 300 records, each with any 400 records needed to describe the
+// per-value quality of its intervals. 500 records that were parsed from a file go back after the 300
+// record they followed there. Any others, including those whose 300 record is no longer in the block,
+// go at the end.
+func (w *Writer) WriteBlock(block *NMIDataBlock) error {
+	inBlock := make(map[*IntervalDataRecord]bool, len(block.Intervals))
+	for _, interval := range block.Intervals {
+		inBlock[interval] = true
+	}
+	var leading, trailing []*B2bDetailsRecord
+	following := make(map[*IntervalDataRecord][]*B2bDetailsRecord)
+	for _, b2b := range block.B2bDetails {
+		switch {
+		case !b2b.placed:
+			trailing = append(trailing, b2b)
+		case b2b.after == nil:
+			leading = append(leading, b2b)
+		case inBlock[b2b.after]:
+			following[b2b.after] = append(following[b2b.after], b2b)
+		default:
+			trailing = append(trailing, b2b)
+		}
+	}
+
+	err := w.write200Record(&block.Details)
+	if err != nil {
+		return err
+	}
+	err = w.write500Records(leading)
+	if err != nil {
+		return err
+	}
+	for _, interval := range block.Intervals {
+		err = w.write300Record(&block.Details, interval)
+		if err != nil {
+			return err
+		}
+		err = w.write400Records(interval)
+		if err != nil {
+			return err
+		}
+		err = w.write500Records(following[interval])
+		if err != nil {
+			return err
+		}
+	}
+	return w.write500Records(trailing)
+}
+
+func (w *Writer) write500Records(b2bs []*B2bDetailsRecord) error {
+	for _, b2b := range b2bs {
+		err := w.write500Record(b2b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) write500Record(b2b *B2bDetailsRecord) error {
+	return w.csv.Write([]string{
+		"500",
+		b2b.TransCode,
+		b2b.RetServiceOrder,
+		formatTime(b2b.ReadDataTime, dateTime14Format),
+		b2b.IndexRead,
+	})
+}
+
+// Writes the 900 end record and flushes everything to the underlying writer
+func (w *Writer) WriteEnd() error {
+	err := w.csv.Write([]string{"900"})
+	if err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *Writer) write200Record(details *NMIDataDetailsRecord) error {
+	return w.csv.Write([]string{
+		"200",
+		details.NMI,
+		details.NMIConfiguration,
+		details.RegisterID,
+		details.NMISuffix,
+		details.MDMDataStreamIdentifier,
+		details.MeterSerialNumber,
+		details.UOM,
+		strconv.Itoa(details.IntervalLength),
+		formatTime(details.NextScheduledReadDate, dateFormat),
+	})
+}
+
+func (w *Writer) write300Record(details *NMIDataDetailsRecord, interval *IntervalDataRecord) error {
+	// As per the spec, "The number of values provided must equal 1440 divided by the IntervalLength"
+	numIntervalVals := 1440 / details.IntervalLength
+	if len(interval.IntervalValues) != numIntervalVals {
+		return fmt.Errorf("interval on %v has %v values but expected %v",
+			interval.IntervalDate.Format(dateFormat), len(interval.IntervalValues), numIntervalVals)
+	}
+	record := make([]string, 0, numIntervalVals+7)
+	record = append(record, "300", interval.IntervalDate.Format(dateFormat))
+	for _, val := range interval.IntervalValues {
+		record = append(record, strconv.FormatFloat(val.Value, 'f', -1, 64))
+	}
+	record = append(record,
+		interval.QualityMethod,
+		formatReasonCode(interval.ReasonCode),
+		interval.ReasonDescription,
+		formatTime(interval.UpdateDateTime, dateTime14Format),
+		formatTime(interval.MSATSLoadDateTime, dateTime14Format),
+	)
+	return w.csv.Write(record)
+}
+
+// Groups consecutive interval values with the same quality data into 400 records. This is the
+// reverse of Parser.adjustInterval.
+func (w *Writer) write400Records(interval *IntervalDataRecord) error {
+	var current *QualityData
+	start := 0
+	flush := func(end int) error {
+		if current == nil {
+			return nil
+		}
+		// Intervals are 1-indexed and closed
+		return w.csv.Write([]string{
+			"400",
+			strconv.Itoa(start + 1),
+			strconv.Itoa(end),
+			current.QualityMethod,
+			formatReasonCode(current.ReasonCode),
+			current.ReasonDescription,
+		})
+	}
+	for i, val := range interval.IntervalValues {
+		if sameQuality(current, val.Quality) {
+			continue
+		}
+		err := flush(i)
+		if err != nil {
+			return err
+		}
+		current = val.Quality
+		start = i
+	}
+	return flush(len(interval.IntervalValues))
+}
+
+func sameQuality(a, b *QualityData) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.QualityMethod != b.QualityMethod || a.ReasonDescription != b.ReasonDescription {
+		return false
+	}
+	if a.ReasonCode == nil || b.ReasonCode == nil {
+		return a.ReasonCode == b.ReasonCode
+	}
+	return *a.ReasonCode == *b.ReasonCode
+}
+
+func formatReasonCode(reasonCode *int) string {
+	if reasonCode == nil {
+		return ""
+	}
+	return strconv.Itoa(*reasonCode)
+}
+
+// Optional date and time fields are left blank if they're not set
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
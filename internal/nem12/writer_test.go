@@ -0,0 +1,97 @@
+package nem12
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseRecordsFrom(t *testing.T, content []byte) *File {
+	t.Helper()
+	parser := NewParser(testLogger(), bytes.NewReader(content), Strict, nil)
+	file, err := parser.ParseFile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	original, err := os.ReadFile("testdata/roundtrip.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := parseRecordsFrom(t, original)
+
+	var written bytes.Buffer
+	err = NewWriter(&written).WriteFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.String() != string(original) {
+		t.Errorf("written file differs from the original\ngot:\n%v\nwant:\n%v", written.String(), string(original))
+	}
+
+	reparsed := parseRecordsFrom(t, written.Bytes())
+	if !reflect.DeepEqual(file, reparsed) {
+		t.Errorf("records changed after a round trip")
+	}
+
+	// Spot check the fields that are easy to lose
+	header := reparsed.Header
+	if header.FromParticipant != "MDP1" || header.ToParticipant != "Retailer1" ||
+		!header.DateTime.Equal(time.Date(2003, 1, 1, 15, 34, 0, 0, time.UTC)) {
+		t.Errorf("got header %+v", header)
+	}
+	details := reparsed.Blocks[0].Details
+	if !details.NextScheduledReadDate.Equal(time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got next scheduled read date %v", details.NextScheduledReadDate)
+	}
+	interval := reparsed.Blocks[0].Intervals[0]
+	if !interval.UpdateDateTime.Equal(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		!interval.MSATSLoadDateTime.Equal(time.Date(2023, 1, 2, 4, 5, 6, 0, time.UTC)) {
+		t.Errorf("got update date times %v and %v", interval.UpdateDateTime, interval.MSATSLoadDateTime)
+	}
+	if len(reparsed.Blocks[0].B2bDetails) != 2 || len(reparsed.Blocks[1].B2bDetails) != 1 {
+		t.Errorf("lost 500 records")
+	}
+}
+
+func TestWriterPlacesB2bDetails(t *testing.T) {
+	original, err := os.ReadFile("testdata/roundtrip.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := parseRecordsFrom(t, original)
+	block := file.Blocks[0]
+	// A 500 record that wasn't parsed goes at the end of the block, as does one whose 300 record
+	// has been removed
+	block.B2bDetails = append(block.B2bDetails, &B2bDetailsRecord{TransCode: "N", IndexRead: "1"})
+	block.Intervals = block.Intervals[:1]
+
+	var written bytes.Buffer
+	writer := NewWriter(&written)
+	err = writer.WriteBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.WriteEnd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indicators []string
+	for _, line := range strings.Split(strings.TrimSpace(written.String()), "\n") {
+		indicators = append(indicators, line[:3])
+	}
+	want := []string{"200", "300", "500", "500", "500", "900"}
+	if !reflect.DeepEqual(indicators, want) {
+		t.Errorf("got records %v, want %v", indicators, want)
+	}
+	if !strings.HasSuffix(written.String(), "500,N,,,1\n900\n") {
+		t.Errorf("the unparsed 500 record wasn't written last:\n%v", written.String())
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A calendar period such as a month or a quarter. Unlike time.Duration, its length in days depends
+// on when it starts.
+type Period struct {
+	Years  int
+	Months int
+	Days   int
+}
+
+// Parses an ISO 8601 duration made up of years, months, weeks and days, e.g. P1M or P1Y6M. Time
+// components such as PT1H aren't supported, since plans only use durations to describe calendar
+// periods.
+func ParsePeriod(duration string) (Period, error) {
+	if len(duration) < 3 || duration[0] != 'P' {
+		return Period{}, fmt.Errorf("invalid ISO 8601 duration %v", duration)
+	}
+	var period Period
+	number := ""
+	for _, r := range duration[1:] {
+		if r >= '0' && r <= '9' {
+			number = number + string(r)
+			continue
+		}
+		if number == "" {
+			return Period{}, fmt.Errorf("invalid ISO 8601 duration %v", duration)
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return Period{}, fmt.Errorf("invalid ISO 8601 duration %v: %w", duration, err)
+		}
+		switch r {
+		case 'Y':
+			period.Years = period.Years + n
+		case 'M':
+			period.Months = period.Months + n
+		case 'W':
+			period.Days = period.Days + n*7
+		case 'D':
+			period.Days = period.Days + n
+		default:
+			return Period{}, fmt.Errorf("unsupported ISO 8601 duration %v", duration)
+		}
+		number = ""
+	}
+	if number != "" || period == (Period{}) {
+		return Period{}, fmt.Errorf("invalid ISO 8601 duration %v", duration)
+	}
+	return period, nil
+}
+
+// Gets the time one period after t
+func (p Period) AddTo(t time.Time) time.Time {
+	return t.AddDate(p.Years, p.Months, p.Days)
+}
+
+// Gets the number of days in the period when it starts at t
+func (p Period) DaysFrom(t time.Time) int {
+	start := StartOfDay(t)
+	return int(StartOfDay(p.AddTo(start)).Sub(start).Round(24*time.Hour) / (24 * time.Hour))
+}
@@ -2,14 +2,13 @@ package util
 
 import "time"
 
+// Checks whether the date is between start and end, including both. If start is after end, the
+// range wraps around, e.g. from November to February.
 func InDateRange(start, end, date time.Time) bool {
-	if start.Equal(end) {
-		return date.Equal(start)
-	}
 	if start.After(end) {
 		return !start.After(date) || !end.Before(date)
 	}
-	return date.After(start) && date.Before(end)
+	return !date.Before(start) && !date.After(end)
 }
 
 func IsMidnight(t time.Time) bool {
@@ -42,3 +41,11 @@ var daysInMonth = []int{
 	30, // Nov
 	31, // Dec
 }
+
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
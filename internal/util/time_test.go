@@ -0,0 +1,36 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInDateRange(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(0, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		date       time.Time
+		want       bool
+	}{
+		{"first day of the year", date(time.January, 1), date(time.December, 31), date(time.January, 1), true},
+		{"last day of the year", date(time.January, 1), date(time.December, 31), date(time.December, 31), true},
+		{"start of a range", date(time.March, 1), date(time.May, 31), date(time.March, 1), true},
+		{"end of a range", date(time.March, 1), date(time.May, 31), date(time.May, 31), true},
+		{"day before a range", date(time.March, 1), date(time.May, 31), date(time.February, 28), false},
+		{"day after a range", date(time.March, 1), date(time.May, 31), date(time.June, 1), false},
+		{"single day", date(time.July, 4), date(time.July, 4), date(time.July, 4), true},
+		{"start of a wrapping range", date(time.November, 1), date(time.February, 28), date(time.November, 1), true},
+		{"end of a wrapping range", date(time.November, 1), date(time.February, 28), date(time.February, 28), true},
+		{"31 Dec in a wrapping range", date(time.November, 1), date(time.February, 28), date(time.December, 31), true},
+		{"1 Jan in a wrapping range", date(time.November, 1), date(time.February, 28), date(time.January, 1), true},
+		{"outside a wrapping range", date(time.November, 1), date(time.February, 28), date(time.June, 1), false},
+	}
+	for _, test := range tests {
+		if got := InDateRange(test.start, test.end, test.date); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}